@@ -0,0 +1,26 @@
+package fridge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+const (
+	chunkSeparator = ":chunk:"
+)
+
+// splitValue splits a value into consecutive chunks of at most chunkSize bytes
+func splitValue(value string, chunkSize int) []string {
+	chunks := make([]string, 0, (len(value)+chunkSize-1)/chunkSize)
+	for len(value) > chunkSize {
+		chunks = append(chunks, value[:chunkSize])
+		value = value[chunkSize:]
+	}
+	return append(chunks, value)
+}
+
+// checksumValue returns the hex encoded SHA-256 of a value
+func checksumValue(value string) string {
+	checksum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(checksum[:])
+}
@@ -0,0 +1,514 @@
+package fridge
+
+import (
+	"errors"
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	clusterSlots = 16384
+
+	defaultClusterMaxRedirects = 5
+
+	movedReply       = "MOVED"
+	askReply         = "ASK"
+	addressSeparator = ":"
+
+	clusterCommand      = "CLUSTER"
+	clusterSlotsCommand = "SLOTS"
+	askingCommand       = "ASKING"
+
+	clusterNodeBits = 48
+	clusterNodeMask = 1<<clusterNodeBits - 1
+
+	noClusterNodesError    = "no reachable redis cluster nodes"
+	tooManyRedirectsError  = "too many redis cluster redirects"
+	invalidSlotsReplyError = "invalid redis cluster slots reply"
+)
+
+// WithClusterAddresses sets the redis cluster seed addresses, which default to the host and port
+func WithClusterAddresses(addresses []string) RedisOption {
+	return func(redisSettings *RedisSettings) {
+		redisSettings.ClusterAddresses = addresses
+	}
+}
+
+// WithClusterMaxRedirects sets how many MOVED and ASK redirects a command may follow
+func WithClusterMaxRedirects(maxRedirects int) RedisOption {
+	return func(redisSettings *RedisSettings) {
+		redisSettings.ClusterMaxRedirects = maxRedirects
+	}
+}
+
+// NewClusterCache creates a new redis cluster client. It accepts the same options as NewRedisCache,
+// which apply to the connections of every node
+func NewClusterCache(options ...RedisOption) *ClusterCache {
+	settings := newRedisSettings(options...)
+
+	seeds := settings.ClusterAddresses
+	if len(seeds) == 0 {
+		seeds = []string{fmt.Sprintf(addressFormat, settings.Host, settings.Port)}
+	}
+
+	return &ClusterCache{
+		settings: settings,
+		seeds:    seeds,
+		pools:    make(map[string]*redis.Pool),
+	}
+}
+
+// ClusterCache contains a slot aware redis cluster client
+type ClusterCache struct {
+	settings   *RedisSettings
+	seeds      []string
+	mutex      sync.RWMutex
+	slots      [clusterSlots]string
+	masters    []string
+	pools      map[string]*redis.Pool
+	refreshing int32
+}
+
+// Get a value by key
+func (c *ClusterCache) Get(key string) (string, bool, error) {
+	value, err := redis.String(c.do(key, getCommand, key))
+	if err == redis.ErrNil {
+		return empty, false, nil
+	}
+
+	if err != nil {
+		return empty, false, err
+	}
+	return value, true, nil
+}
+
+// Set a key value pair
+func (c *ClusterCache) Set(key string, value string, timeout time.Duration) error {
+	milliseconds, err := timeoutMilliseconds(timeout)
+	if err != nil {
+		return err
+	}
+
+	if milliseconds == 0 {
+		_, err := c.do(key, setCommand, key, value)
+		return err
+	}
+
+	_, err = c.do(key, setCommand, key, value, expireMillisecondsOption, milliseconds)
+	return err
+}
+
+// Remove a key
+func (c *ClusterCache) Remove(key string) error {
+	_, err := c.do(key, delCommand, key)
+	return err
+}
+
+// TTL returns the remaining time to live of a key, or NoExpiration when it does not expire
+func (c *ClusterCache) TTL(key string) (time.Duration, bool, error) {
+	return parseTTL(redis.Int64(c.do(key, pttlCommand, key)))
+}
+
+// AddMembers adds members to a set, which expires after the longest timeout it was given, or never when given NoExpiration
+func (c *ClusterCache) AddMembers(key string, timeout time.Duration, members ...string) error {
+	do := func(command string, arguments ...interface{}) (interface{}, error) {
+		return c.do(key, command, arguments...)
+	}
+	return addMembers(do, key, timeout, members...)
+}
+
+// Members returns the members of a set
+func (c *ClusterCache) Members(key string) ([]string, error) {
+	return redis.Strings(c.do(key, smembersCommand, key))
+}
+
+// RemoveMembers removes members from a set
+func (c *ClusterCache) RemoveMembers(key string, members ...string) error {
+	_, err := c.do(key, sremCommand, redis.Args{}.Add(key).AddFlat(members)...)
+	return err
+}
+
+// Expire sets when a key expires, or that it never does when given NoExpiration, and returns whether the key exists
+func (c *ClusterCache) Expire(key string, timeout time.Duration) (bool, error) {
+	do := func(command string, arguments ...interface{}) (interface{}, error) {
+		return c.do(key, command, arguments...)
+	}
+	return expire(do, key, timeout)
+}
+
+// Increment increments a counter and returns its new value
+func (c *ClusterCache) Increment(key string) (int64, error) {
+	return redis.Int64(c.do(key, incrCommand, key))
+}
+
+// Scan keys matching a pattern starting from a cursor.
+// The cursor encodes the index of the master being scanned in its upper bits
+func (c *ClusterCache) Scan(cursor int64, pattern string) (int64, []string, error) {
+	masters, err := c.getMasters()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	index := int(cursor >> clusterNodeBits)
+	if index >= len(masters) {
+		return 0, []string{}, nil
+	}
+
+	connection := c.getPool(masters[index]).Get()
+	defer connection.Close()
+
+	results, err := redis.Values(connection.Do(scanCommand, cursor&clusterNodeMask, matchOption, pattern))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var nodeCursor int64
+	var keys []string
+	_, err = redis.Scan(results, &nodeCursor, &keys)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if nodeCursor != 0 {
+		return int64(index)<<clusterNodeBits | nodeCursor, keys, nil
+	}
+
+	if index+1 >= len(masters) {
+		return 0, keys, nil
+	}
+	return int64(index+1) << clusterNodeBits, keys, nil
+}
+
+// RemoveAll removes a batch of keys
+func (c *ClusterCache) RemoveAll(keys ...string) error {
+	for _, key := range keys {
+		err := c.Remove(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ping to test connectivity
+func (c *ClusterCache) Ping() error {
+	masters, err := c.getMasters()
+	if err != nil {
+		return err
+	}
+
+	for _, master := range masters {
+		connection := c.getPool(master).Get()
+		_, err := connection.Do(pingCommand)
+		connection.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close to close resources
+func (c *ClusterCache) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var closeErr error
+	for address, pool := range c.pools {
+		err := pool.Close()
+		if err != nil && closeErr == nil {
+			closeErr = err
+		}
+		delete(c.pools, address)
+	}
+	return closeErr
+}
+
+func (c *ClusterCache) keyLayout() KeyLayout {
+	return NewHashTagLayout()
+}
+
+func (c *ClusterCache) do(key string, command string, arguments ...interface{}) (interface{}, error) {
+	slot := clusterSlot(key)
+	address, err := c.getSlotAddress(slot)
+	if err != nil {
+		return nil, err
+	}
+
+	asking := false
+	for redirects := 0; redirects <= c.settings.ClusterMaxRedirects; redirects++ {
+		reply, err := c.doOnNode(address, asking, command, arguments...)
+		if err == nil {
+			return reply, nil
+		}
+
+		redisError, ok := err.(redis.Error)
+		if !ok {
+			if _, ok := err.(net.Error); ok {
+				c.refreshAsync()
+			}
+			return nil, err
+		}
+
+		redirect, redirectSlot, redirectAddress, ok := parseRedirect(string(redisError))
+		if !ok {
+			return nil, err
+		}
+
+		address = resolveRedirectAddress(address, redirectAddress)
+		asking = redirect == askReply
+		if redirect == movedReply {
+			c.setSlotAddress(redirectSlot, address)
+			c.refreshAsync()
+		}
+	}
+	return nil, errors.New(tooManyRedirectsError)
+}
+
+func (c *ClusterCache) doOnNode(address string, asking bool, command string, arguments ...interface{}) (interface{}, error) {
+	connection := c.getPool(address).Get()
+	defer connection.Close()
+
+	if asking {
+		_, err := connection.Do(askingCommand)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return connection.Do(command, arguments...)
+}
+
+func (c *ClusterCache) getSlotAddress(slot int) (string, error) {
+	c.mutex.RLock()
+	address := c.slots[slot]
+	c.mutex.RUnlock()
+
+	if len(address) > 0 {
+		return address, nil
+	}
+
+	err := c.refresh()
+	if err != nil {
+		return empty, err
+	}
+
+	c.mutex.RLock()
+	address = c.slots[slot]
+	c.mutex.RUnlock()
+
+	if len(address) > 0 {
+		return address, nil
+	}
+	return c.seeds[0], nil
+}
+
+func (c *ClusterCache) setSlotAddress(slot int, address string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.slots[slot] = address
+}
+
+func (c *ClusterCache) getMasters() ([]string, error) {
+	c.mutex.RLock()
+	masters := c.masters
+	c.mutex.RUnlock()
+
+	if len(masters) > 0 {
+		return masters, nil
+	}
+
+	err := c.refresh()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.masters, nil
+}
+
+func (c *ClusterCache) getPool(address string) *redis.Pool {
+	c.mutex.RLock()
+	pool, ok := c.pools[address]
+	c.mutex.RUnlock()
+
+	if ok {
+		return pool
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	pool, ok = c.pools[address]
+	if ok {
+		return pool
+	}
+
+	pool = c.newPool(address)
+	c.pools[address] = pool
+	return pool
+}
+
+func (c *ClusterCache) newPool(address string) *redis.Pool {
+	dial := func() (redis.Conn, error) {
+		return dialRedis(c.settings, address)
+	}
+	return newRedisPool(c.settings, dial, testOnBorrow(c.settings, nil))
+}
+
+func (c *ClusterCache) refreshAsync() {
+	if !atomic.CompareAndSwapInt32(&c.refreshing, 0, 1) {
+		return
+	}
+
+	go func() {
+		defer atomic.StoreInt32(&c.refreshing, 0)
+		c.refresh()
+	}()
+}
+
+func (c *ClusterCache) refresh() error {
+	c.mutex.RLock()
+	addresses := append(append([]string{}, c.masters...), c.seeds...)
+	c.mutex.RUnlock()
+
+	var lastErr error = errors.New(noClusterNodesError)
+	for _, address := range addresses {
+		slots, masters, err := c.fetchSlots(address)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		c.mutex.Lock()
+		c.slots = *slots
+		c.masters = masters
+		c.mutex.Unlock()
+		return nil
+	}
+	return lastErr
+}
+
+func (c *ClusterCache) fetchSlots(address string) (*[clusterSlots]string, []string, error) {
+	connection := c.getPool(address).Get()
+	defer connection.Close()
+
+	ranges, err := redis.Values(connection.Do(clusterCommand, clusterSlotsCommand))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	slots := &[clusterSlots]string{}
+	masterSet := make(map[string]bool)
+	for _, value := range ranges {
+		slotRange, err := redis.Values(value, nil)
+		if err != nil || len(slotRange) < 3 {
+			return nil, nil, errors.New(invalidSlotsReplyError)
+		}
+
+		start, err := redis.Int(slotRange[0], nil)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		end, err := redis.Int(slotRange[1], nil)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		node, err := redis.Values(slotRange[2], nil)
+		if err != nil || len(node) < 2 {
+			return nil, nil, errors.New(invalidSlotsReplyError)
+		}
+
+		host, err := redis.String(node[0], nil)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		port, err := redis.Int(node[1], nil)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if start < 0 || end >= clusterSlots || start > end {
+			return nil, nil, errors.New(invalidSlotsReplyError)
+		}
+
+		master := net.JoinHostPort(host, strconv.Itoa(port))
+		masterSet[master] = true
+		for slot := start; slot <= end; slot++ {
+			slots[slot] = master
+		}
+	}
+
+	masters := make([]string, 0, len(masterSet))
+	for master := range masterSet {
+		masters = append(masters, master)
+	}
+	sort.Strings(masters)
+	return slots, masters, nil
+}
+
+func parseRedirect(message string) (string, int, string, bool) {
+	fields := strings.Fields(message)
+	if len(fields) != 3 || (fields[0] != movedReply && fields[0] != askReply) {
+		return empty, 0, empty, false
+	}
+
+	slot, err := strconv.Atoi(fields[1])
+	if err != nil || slot < 0 || slot >= clusterSlots {
+		return empty, 0, empty, false
+	}
+	return fields[0], slot, fields[2], true
+}
+
+// resolveRedirectAddress fills in the host of a redirect that only carries a port
+func resolveRedirectAddress(address string, redirectAddress string) string {
+	if !strings.HasPrefix(redirectAddress, addressSeparator) {
+		return redirectAddress
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return redirectAddress
+	}
+	return host + redirectAddress
+}
+
+// clusterSlot returns the redis cluster hash slot of a key, honoring hash tags
+func clusterSlot(key string) int {
+	start := strings.Index(key, hashTagOpen)
+	if start >= 0 {
+		end := strings.Index(key[start+1:], hashTagClose)
+		if end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % clusterSlots)
+}
+
+// crc16 implements the CRC16-CCITT (XMODEM) checksum used by redis cluster
+func crc16(key string) uint16 {
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
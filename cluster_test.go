@@ -0,0 +1,577 @@
+package fridge
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeCluster struct {
+	mutex     sync.Mutex
+	nodes     []*fakeNode
+	owners    [clusterSlots]int
+	migrating map[int]int
+
+	// credentials of the last AUTH command
+	credentials []string
+
+	// portOnlyRedirects leaves the host out of redirects, as nodes without a cluster-announce-ip do
+	portOnlyRedirects bool
+}
+
+type fakeNode struct {
+	index    int
+	listener net.Listener
+	data     map[string]string
+	sets     map[string]map[string]bool
+}
+
+func newFakeCluster(t *testing.T, size int) *fakeCluster {
+	cluster := &fakeCluster{migrating: make(map[int]int)}
+	for index := 0; index < size; index++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+
+		node := &fakeNode{index: index, listener: listener, data: make(map[string]string), sets: make(map[string]map[string]bool)}
+		cluster.nodes = append(cluster.nodes, node)
+		go cluster.serve(node)
+	}
+
+	for slot := 0; slot < clusterSlots; slot++ {
+		cluster.owners[slot] = slot * size / clusterSlots
+	}
+	return cluster
+}
+
+func (f *fakeCluster) close() {
+	for _, node := range f.nodes {
+		node.listener.Close()
+	}
+}
+
+func (f *fakeCluster) addresses() []string {
+	return []string{f.nodes[0].listener.Addr().String()}
+}
+
+func (f *fakeCluster) move(key string, target int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	slot := clusterSlot(key)
+	source := f.nodes[f.owners[slot]]
+	value, ok := source.data[key]
+	if ok {
+		delete(source.data, key)
+		f.nodes[target].data[key] = value
+	}
+	f.owners[slot] = target
+}
+
+func (f *fakeCluster) migrate(key string, target int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	slot := clusterSlot(key)
+	source := f.nodes[f.owners[slot]]
+	value, ok := source.data[key]
+	if ok {
+		delete(source.data, key)
+		f.nodes[target].data[key] = value
+	}
+	f.migrating[slot] = target
+}
+
+func (f *fakeCluster) serve(node *fakeNode) {
+	for {
+		connection, err := node.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(node, connection)
+	}
+}
+
+func (f *fakeCluster) handle(node *fakeNode, connection net.Conn) {
+	defer connection.Close()
+
+	reader := bufio.NewReader(connection)
+	asking := false
+	for {
+		arguments, err := readFakeCommand(reader)
+		if err != nil {
+			return
+		}
+
+		command := strings.ToUpper(arguments[0])
+		if command == askingCommand {
+			asking = true
+			io.WriteString(connection, "+OK\r\n")
+			continue
+		}
+
+		io.WriteString(connection, f.execute(node, asking, command, arguments[1:]))
+		asking = false
+	}
+}
+
+func (f *fakeCluster) execute(node *fakeNode, asking bool, command string, arguments []string) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	switch command {
+	case pingCommand:
+		return "+PONG\r\n"
+	case authCommand:
+		f.credentials = arguments
+		return "+OK\r\n"
+	case clusterCommand:
+		return f.slots()
+	case scanCommand:
+		keys := []string{}
+		for key := range node.data {
+			matched, _ := path.Match(arguments[2], key)
+			if matched {
+				keys = append(keys, key)
+			}
+		}
+		return "*2\r\n" + fakeBulk("0") + fakeArray(keys)
+	}
+
+	key := arguments[0]
+	slot := clusterSlot(key)
+	owner := f.owners[slot]
+	target, migrating := f.migrating[slot]
+	_, exists := node.data[key]
+
+	switch {
+	case owner == node.index && migrating && !exists:
+		return fmt.Sprintf("-ASK %d %s\r\n", slot, f.redirectAddress(target))
+	case owner != node.index && !(asking && migrating && target == node.index):
+		return fmt.Sprintf("-MOVED %d %s\r\n", slot, f.redirectAddress(owner))
+	}
+
+	switch command {
+	case getCommand:
+		value, ok := node.data[key]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fakeBulk(value)
+	case setCommand:
+		node.data[key] = arguments[1]
+		return "+OK\r\n"
+	case pttlCommand:
+		_, ok := node.data[key]
+		_, isSet := node.sets[key]
+		if !ok && !isSet {
+			return ":-2\r\n"
+		}
+		return ":-1\r\n"
+	case pexpireCommand, existsCommand:
+		_, ok := node.data[key]
+		_, isSet := node.sets[key]
+		if !ok && !isSet {
+			return ":0\r\n"
+		}
+		return ":1\r\n"
+	case persistCommand:
+		return ":0\r\n"
+	case saddCommand:
+		set, ok := node.sets[key]
+		if !ok {
+			set = make(map[string]bool)
+			node.sets[key] = set
+		}
+
+		for _, member := range arguments[1:] {
+			set[member] = true
+		}
+		return fmt.Sprintf(":%d\r\n", len(arguments)-1)
+	case smembersCommand:
+		members := []string{}
+		for member := range node.sets[key] {
+			members = append(members, member)
+		}
+		return fakeArray(members)
+	case sremCommand:
+		for _, member := range arguments[1:] {
+			delete(node.sets[key], member)
+		}
+		return fmt.Sprintf(":%d\r\n", len(arguments)-1)
+	case incrCommand:
+		value, _ := strconv.Atoi(node.data[key])
+		node.data[key] = strconv.Itoa(value + 1)
+		return fmt.Sprintf(":%d\r\n", value+1)
+	case delCommand:
+		_, ok := node.data[key]
+		delete(node.data, key)
+		if !ok {
+			return ":0\r\n"
+		}
+		return ":1\r\n"
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func (f *fakeCluster) redirectAddress(index int) string {
+	address := f.nodes[index].listener.Addr().String()
+	if !f.portOnlyRedirects {
+		return address
+	}
+
+	_, port, _ := net.SplitHostPort(address)
+	return addressSeparator + port
+}
+
+func (f *fakeCluster) slots() string {
+	ranges := []string{}
+	start := 0
+	for slot := 1; slot <= clusterSlots; slot++ {
+		if slot < clusterSlots && f.owners[slot] == f.owners[start] {
+			continue
+		}
+
+		host, port, _ := net.SplitHostPort(f.nodes[f.owners[start]].listener.Addr().String())
+		node := "*2\r\n" + fakeBulk(host) + ":" + port + "\r\n"
+		ranges = append(ranges, fmt.Sprintf("*3\r\n:%d\r\n:%d\r\n%s", start, slot-1, node))
+		start = slot
+	}
+	return fmt.Sprintf("*%d\r\n%s", len(ranges), strings.Join(ranges, empty))
+}
+
+func readFakeCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	arguments := make([]string, count)
+	for index := range arguments {
+		line, err = reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		length, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+
+		buffer := make([]byte, length+2)
+		_, err = io.ReadFull(reader, buffer)
+		if err != nil {
+			return nil, err
+		}
+		arguments[index] = string(buffer[:length])
+	}
+	return arguments, nil
+}
+
+func fakeBulk(value string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+}
+
+func fakeArray(values []string) string {
+	array := fmt.Sprintf("*%d\r\n", len(values))
+	for _, value := range values {
+		array += fakeBulk(value)
+	}
+	return array
+}
+
+func TestClusterCache_Slot(t *testing.T) {
+	assert.Equal(t, clusterSlot("123456789"), 12739)
+	assert.Equal(t, clusterSlot("foo"), 12182)
+	assert.Equal(t, clusterSlot("{food}.config"), clusterSlot("food"))
+	assert.Equal(t, clusterSlot("{}food"), int(crc16("{}food")%clusterSlots))
+}
+
+func TestClusterCache_SetGetRemove(t *testing.T) {
+	cluster := newFakeCluster(t, 3)
+	defer cluster.close()
+
+	cache := NewClusterCache(WithClusterAddresses(cluster.addresses()))
+	defer cache.Close()
+
+	assert.Nil(t, cache.Ping())
+
+	for index := 0; index < 20; index++ {
+		key := fmt.Sprintf("food%d", index)
+		assert.Nil(t, cache.Set(key, key, 0))
+
+		value, found, err := cache.Get(key)
+		assert.Nil(t, err)
+		assert.True(t, found)
+		assert.Equal(t, value, key)
+	}
+
+	for _, node := range cluster.nodes {
+		assert.NotEmpty(t, node.data)
+	}
+
+	assert.Nil(t, cache.Remove("food1"))
+
+	_, found, err := cache.Get("food1")
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
+
+func TestClusterCache_TTL(t *testing.T) {
+	cluster := newFakeCluster(t, 2)
+	defer cluster.close()
+
+	cache := NewClusterCache(WithClusterAddresses(cluster.addresses()))
+	defer cache.Close()
+
+	_, found, err := cache.TTL("food")
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	assert.Nil(t, cache.Set("food", "Pizza", 0))
+
+	ttl, found, err := cache.TTL("food")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, ttl, NoExpiration)
+}
+
+func TestClusterCache_Members(t *testing.T) {
+	cluster := newFakeCluster(t, 2)
+	defer cluster.close()
+
+	cache := NewClusterCache(WithClusterAddresses(cluster.addresses()))
+	defer cache.Close()
+
+	assert.Nil(t, cache.AddMembers("tag:food", time.Minute, "pizza", "pasta"))
+	assert.Nil(t, cache.RemoveMembers("tag:food", "pasta"))
+
+	members, err := cache.Members("tag:food")
+	assert.Nil(t, err)
+	assert.Equal(t, members, []string{"pizza"})
+}
+
+func TestClusterCache_Expire(t *testing.T) {
+	cluster := newFakeCluster(t, 2)
+	defer cluster.close()
+
+	cache := NewClusterCache(WithClusterAddresses(cluster.addresses()))
+	defer cache.Close()
+
+	found, err := cache.Expire("food", time.Minute)
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	assert.Nil(t, cache.Set("food", "Pizza", NoExpiration))
+
+	found, err = cache.Expire("food", time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, found)
+
+	found, err = cache.Expire("food", NoExpiration)
+	assert.Nil(t, err)
+	assert.True(t, found)
+}
+
+func TestClusterCache_Increment(t *testing.T) {
+	cluster := newFakeCluster(t, 2)
+	defer cluster.close()
+
+	cache := NewClusterCache(WithClusterAddresses(cluster.addresses()))
+	defer cache.Close()
+
+	value, err := cache.Increment("generation:current")
+	assert.Nil(t, err)
+	assert.Equal(t, value, int64(1))
+
+	value, err = cache.Increment("generation:current")
+	assert.Nil(t, err)
+	assert.Equal(t, value, int64(2))
+}
+
+func TestClusterCache_Moved(t *testing.T) {
+	cluster := newFakeCluster(t, 2)
+	defer cluster.close()
+
+	cache := NewClusterCache(WithClusterAddresses(cluster.addresses()))
+	defer cache.Close()
+
+	assert.Nil(t, cache.Set("food", "Pizza", 0))
+
+	owner := cluster.owners[clusterSlot("food")]
+	cluster.move("food", 1-owner)
+
+	value, found, err := cache.Get("food")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, value, "Pizza")
+
+	address, err := cache.getSlotAddress(clusterSlot("food"))
+	assert.Nil(t, err)
+	assert.Equal(t, address, cluster.nodes[1-owner].listener.Addr().String())
+}
+
+func TestClusterCache_MovedPortOnly(t *testing.T) {
+	cluster := newFakeCluster(t, 2)
+	cluster.portOnlyRedirects = true
+	defer cluster.close()
+
+	cache := NewClusterCache(WithClusterAddresses(cluster.addresses()))
+	defer cache.Close()
+
+	assert.Nil(t, cache.Set("food", "Pizza", 0))
+
+	owner := cluster.owners[clusterSlot("food")]
+	cluster.move("food", 1-owner)
+	atomic.StoreInt32(&cache.refreshing, 1)
+
+	value, found, err := cache.Get("food")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, value, "Pizza")
+
+	address, err := cache.getSlotAddress(clusterSlot("food"))
+	assert.Nil(t, err)
+	assert.Equal(t, address, cluster.nodes[1-owner].listener.Addr().String())
+}
+
+func TestClusterCache_UseTls(t *testing.T) {
+	cluster := newFakeCluster(t, 1)
+	defer cluster.close()
+
+	cache := NewClusterCache(WithClusterAddresses(cluster.addresses()))
+	defer cache.Close()
+
+	assert.Nil(t, cache.Ping())
+
+	tlsCache := NewClusterCache(WithClusterAddresses(cluster.addresses()), WithUseTls(true), WithTlsSkipVerify(true))
+	defer tlsCache.Close()
+
+	assert.NotNil(t, tlsCache.Ping())
+}
+
+func TestClusterCache_Credentials(t *testing.T) {
+	cluster := newFakeCluster(t, 1)
+	defer cluster.close()
+
+	cache := NewClusterCache(WithClusterAddresses(cluster.addresses()), WithCredentialsProvider(func() (string, string, error) {
+		return "fridge", "secret", nil
+	}))
+	defer cache.Close()
+
+	assert.Nil(t, cache.Ping())
+
+	cluster.mutex.Lock()
+	defer cluster.mutex.Unlock()
+	assert.Equal(t, cluster.credentials, []string{"fridge", "secret"})
+}
+
+func TestClusterCache_Ask(t *testing.T) {
+	cluster := newFakeCluster(t, 2)
+	defer cluster.close()
+
+	cache := NewClusterCache(WithClusterAddresses(cluster.addresses()))
+	defer cache.Close()
+
+	assert.Nil(t, cache.Set("food", "Pizza", 0))
+
+	owner := cluster.owners[clusterSlot("food")]
+	cluster.migrate("food", 1-owner)
+
+	value, found, err := cache.Get("food")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, value, "Pizza")
+
+	address, err := cache.getSlotAddress(clusterSlot("food"))
+	assert.Nil(t, err)
+	assert.Equal(t, address, cluster.nodes[owner].listener.Addr().String())
+}
+
+func TestClusterCache_Scan(t *testing.T) {
+	cluster := newFakeCluster(t, 3)
+	defer cluster.close()
+
+	cache := NewClusterCache(WithClusterAddresses(cluster.addresses()))
+	defer cache.Close()
+
+	for index := 0; index < 20; index++ {
+		assert.Nil(t, cache.Set(fmt.Sprintf("{food%d}.config", index), "Pizza", 0))
+		assert.Nil(t, cache.Set(fmt.Sprintf("{food%d}", index), "Pizza", 0))
+	}
+
+	keys := []string{}
+	var cursor int64
+	for {
+		nextCursor, batch, err := cache.Scan(cursor, "{*}.config")
+		assert.Nil(t, err)
+
+		keys = append(keys, batch...)
+		if nextCursor == 0 {
+			break
+		}
+		cursor = nextCursor
+	}
+	assert.Len(t, keys, 20)
+}
+
+func TestClusterCache_Client(t *testing.T) {
+	cluster := newFakeCluster(t, 3)
+	defer cluster.close()
+
+	client := NewClient(NewClusterCache(WithClusterAddresses(cluster.addresses())))
+	defer client.Close()
+
+	assert.IsType(t, &hashTagLayout{}, client.defaults.KeyLayout)
+
+	assert.Nil(t, client.Put("food", "Pizza"))
+
+	value, found, err := client.Get("food")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, value, "Pizza")
+
+	owner := cluster.owners[clusterSlot("food")]
+	assert.Contains(t, cluster.nodes[owner].data, "{food}")
+	assert.Contains(t, cluster.nodes[owner].data, "{food}.config")
+}
+
+func TestClusterCache_WithClusterAddresses(t *testing.T) {
+	redisSettings := &RedisSettings{}
+
+	redisOption := WithClusterAddresses([]string{"localhost:7000"})
+	redisOption(redisSettings)
+
+	assert.Equal(t, redisSettings.ClusterAddresses, []string{"localhost:7000"})
+}
+
+func TestClusterCache_WithClusterMaxRedirects(t *testing.T) {
+	redisSettings := &RedisSettings{}
+
+	redisOption := WithClusterMaxRedirects(3)
+	redisOption(redisSettings)
+
+	assert.Equal(t, redisSettings.ClusterMaxRedirects, 3)
+}
+
+func TestClusterCache_Seeds(t *testing.T) {
+	cache := NewClusterCache(WithHost("redis"), WithPort(7000))
+	assert.Equal(t, cache.seeds, []string{"redis:7000"})
+	assert.Equal(t, cache.settings.ClusterMaxRedirects, defaultClusterMaxRedirects)
+
+	cache = NewClusterCache(WithClusterAddresses([]string{"localhost:7000", "localhost:7001"}))
+	assert.Equal(t, cache.seeds, []string{"localhost:7000", "localhost:7001"})
+}
@@ -0,0 +1,203 @@
+package fridge
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	// GzipCompressionID identifies values compressed with gzip
+	GzipCompressionID byte = 1
+
+	// ZstdCompressionID identifies values compressed with zstd
+	ZstdCompressionID byte = 2
+
+	// SnappyCompressionID identifies values compressed with snappy
+	SnappyCompressionID byte = 3
+)
+
+const (
+	valueMarker          = "\x00"
+	uncompressedID  byte = 0
+	valueHeaderSize      = 2
+
+	truncatedValueError           = "encoded value is truncated"
+	unknownCompressionErrorFormat = "unknown compression id %d"
+)
+
+var (
+	// builtinCompressors decode values whose compressor is no longer configured
+	builtinCompressors = []Compressor{NewGzipCompressor(gzip.DefaultCompression), NewZstdCompressor(zstd.SpeedDefault), NewSnappyCompressor()}
+)
+
+// Compressor compresses values before they are stored in the cache
+type Compressor interface {
+	// ID identifies the compressor in the header byte of the values it compresses. 0 and 255 are reserved
+	ID() byte
+
+	// Compress a value
+	Compress(value []byte) ([]byte, error)
+
+	// Decompress a value
+	Decompress(value []byte) ([]byte, error)
+}
+
+// NewGzipCompressor returns a gzip compressor using a compression level from compress/gzip
+func NewGzipCompressor(level int) Compressor {
+	return &gzipCompressor{level: level}
+}
+
+type gzipCompressor struct {
+	level int
+}
+
+func (c *gzipCompressor) ID() byte {
+	return GzipCompressionID
+}
+
+func (c *gzipCompressor) Compress(value []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buffer, c.level)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = writer.Write(value)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (c *gzipCompressor) Decompress(value []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(value))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ioutil.ReadAll(reader)
+}
+
+// NewZstdCompressor returns a zstd compressor using an encoder level from github.com/klauspost/compress/zstd
+func NewZstdCompressor(level zstd.EncoderLevel) Compressor {
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(level))
+	if err != nil {
+		return &zstdCompressor{err: err}
+	}
+
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return &zstdCompressor{err: err}
+	}
+	return &zstdCompressor{encoder: encoder, decoder: decoder}
+}
+
+type zstdCompressor struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+	err     error
+}
+
+func (c *zstdCompressor) ID() byte {
+	return ZstdCompressionID
+}
+
+func (c *zstdCompressor) Compress(value []byte) ([]byte, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.encoder.EncodeAll(value, nil), nil
+}
+
+func (c *zstdCompressor) Decompress(value []byte) ([]byte, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.decoder.DecodeAll(value, nil)
+}
+
+// NewSnappyCompressor returns a snappy compressor, which trades compression ratio for speed
+func NewSnappyCompressor() Compressor {
+	return &snappyCompressor{}
+}
+
+type snappyCompressor struct {
+}
+
+func (c *snappyCompressor) ID() byte {
+	return SnappyCompressionID
+}
+
+func (c *snappyCompressor) Compress(value []byte) ([]byte, error) {
+	return snappy.Encode(nil, value), nil
+}
+
+func (c *snappyCompressor) Decompress(value []byte) ([]byte, error) {
+	return snappy.Decode(nil, value)
+}
+
+// compressValue compresses values at or above the threshold. Compressed values, and uncompressed
+// values that happen to start with the marker, are prefixed with the marker and a compressor id
+func compressValue(value string, compressor Compressor, threshold int) (string, error) {
+	if compressor == nil || len(value) < threshold {
+		return markUncompressed(value), nil
+	}
+
+	compressed, err := compressor.Compress([]byte(value))
+	if err != nil {
+		return empty, err
+	}
+
+	if len(compressed)+valueHeaderSize >= len(value) {
+		return markUncompressed(value), nil
+	}
+	return valueMarker + string([]byte{compressor.ID()}) + string(compressed), nil
+}
+
+// decompressValue reverses compressValue. Values without the marker are returned as is
+func decompressValue(value string, compressors ...Compressor) (string, error) {
+	if !strings.HasPrefix(value, valueMarker) {
+		return value, nil
+	}
+
+	if len(value) < valueHeaderSize {
+		return empty, errors.New(truncatedValueError)
+	}
+
+	id := value[1]
+	if id == uncompressedID {
+		return value[valueHeaderSize:], nil
+	}
+
+	for _, compressor := range compressors {
+		if compressor == nil || compressor.ID() != id {
+			continue
+		}
+
+		decompressed, err := compressor.Decompress([]byte(value[valueHeaderSize:]))
+		if err != nil {
+			return empty, err
+		}
+		return string(decompressed), nil
+	}
+	return empty, fmt.Errorf(unknownCompressionErrorFormat, id)
+}
+
+func markUncompressed(value string) string {
+	if !strings.HasPrefix(value, valueMarker) {
+		return value
+	}
+	return valueMarker + string([]byte{uncompressedID}) + value
+}
@@ -0,0 +1,99 @@
+package fridge
+
+import (
+	"compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCompression_Gzip(t *testing.T) {
+	compressor := NewGzipCompressor(gzip.BestSpeed)
+	value := strings.Repeat("Pizza", 100)
+
+	compressed, err := compressValue(value, compressor, 100)
+	assert.Nil(t, err)
+	assert.True(t, len(compressed) < len(value))
+	assert.Equal(t, compressed[1], GzipCompressionID)
+
+	decompressed, err := decompressValue(compressed, compressor)
+	assert.Nil(t, err)
+	assert.Equal(t, decompressed, value)
+}
+
+func TestCompression_Zstd(t *testing.T) {
+	compressor := NewZstdCompressor(zstd.SpeedFastest)
+	value := strings.Repeat("Pizza", 100)
+
+	compressed, err := compressValue(value, compressor, 100)
+	assert.Nil(t, err)
+	assert.True(t, len(compressed) < len(value))
+	assert.Equal(t, compressed[1], ZstdCompressionID)
+
+	decompressed, err := decompressValue(compressed, compressor)
+	assert.Nil(t, err)
+	assert.Equal(t, decompressed, value)
+}
+
+func TestCompression_Snappy(t *testing.T) {
+	compressor := NewSnappyCompressor()
+	value := strings.Repeat("Pizza", 100)
+
+	compressed, err := compressValue(value, compressor, 100)
+	assert.Nil(t, err)
+	assert.True(t, len(compressed) < len(value))
+	assert.Equal(t, compressed[1], SnappyCompressionID)
+
+	decompressed, err := decompressValue(compressed, compressor)
+	assert.Nil(t, err)
+	assert.Equal(t, decompressed, value)
+}
+
+func TestCompression_Builtin(t *testing.T) {
+	value := strings.Repeat("Pizza", 100)
+	for _, compressor := range []Compressor{NewGzipCompressor(gzip.BestSpeed), NewZstdCompressor(zstd.SpeedFastest), NewSnappyCompressor()} {
+		compressed, err := compressValue(value, compressor, 100)
+		assert.Nil(t, err)
+
+		decompressed, err := decompressValue(compressed, builtinCompressors...)
+		assert.Nil(t, err)
+		assert.Equal(t, decompressed, value)
+	}
+}
+
+func TestCompression_Threshold(t *testing.T) {
+	compressor := NewGzipCompressor(gzip.BestSpeed)
+
+	compressed, err := compressValue("Pizza", compressor, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, compressed, "Pizza")
+
+	compressed, err = compressValue("Pizza", nil, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, compressed, "Pizza")
+
+	decompressed, err := decompressValue("Pizza", compressor)
+	assert.Nil(t, err)
+	assert.Equal(t, decompressed, "Pizza")
+}
+
+func TestCompression_Marker(t *testing.T) {
+	value := "\x00\x01Pizza"
+
+	compressed, err := compressValue(value, nil, 0)
+	assert.Nil(t, err)
+	assert.NotEqual(t, compressed, value)
+
+	decompressed, err := decompressValue(compressed)
+	assert.Nil(t, err)
+	assert.Equal(t, decompressed, value)
+}
+
+func TestCompression_Errors(t *testing.T) {
+	_, err := decompressValue("\x00")
+	assert.NotNil(t, err)
+
+	_, err = decompressValue("\x00\x09Pizza", NewGzipCompressor(gzip.BestSpeed))
+	assert.NotNil(t, err)
+}
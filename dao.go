@@ -1,46 +1,279 @@
 package fridge
 
 import (
+	"errors"
 	"fmt"
 	"github.com/shomali11/util/xconversions"
+	"strconv"
+	"strings"
 	"time"
 )
 
 const (
-	configKeyFormat = "%s.config"
+	corruptMetadataErrorFormat    = "corrupt storage details for key '%s': %v"
+	unsupportedVersionErrorFormat = "unsupported storage details version %d"
+	nullStorageDetailsError       = "storage details are null"
+	sweepNotSupportedError        = "cache does not support sweeping"
+	tagsNotSupportedError         = "cache does not support tags"
+	expirationNotSupportedError   = "cache does not support expiration"
+	tagPrefix                     = "tag:"
+	namespaceSeparator            = ":"
+	wildcard                      = "*"
+	defaultSweepBatchSize         = 100
 )
 
+// CorruptMetadataError is returned when a key's storage details cannot be decoded
+type CorruptMetadataError struct {
+	Key string
+	Err error
+}
+
+// Error returns the error message
+func (e *CorruptMetadataError) Error() string {
+	return fmt.Sprintf(corruptMetadataErrorFormat, e.Key, e.Err)
+}
+
 // Dao controls access to redis
 type Dao struct {
-	cache Cache
+	cache       Cache
+	defaults    *Defaults
+	generations *generations
+
+	// prefix is the namespace prefix resolved for a single operation, see resolve
+	prefix *string
 }
 
 // Get retrieves an item
 func (d *Dao) Get(key string) (string, bool, error) {
-	return d.cache.Get(key)
+	d, err := d.resolve()
+	if err != nil {
+		return empty, false, err
+	}
+
+	value, found, err := d.cache.Get(d.valueKey(key))
+	if err != nil || !found {
+		return empty, found, err
+	}
+
+	value, err = d.decode(key, value)
+	if err != nil {
+		return empty, false, err
+	}
+	return value, true, nil
 }
 
 // Set stores a value
 func (d *Dao) Set(key string, value string, timeout time.Duration) error {
-	return d.cache.Set(key, value, timeout)
+	d, err := d.resolve()
+	if err != nil {
+		return err
+	}
+
+	value, err = d.encode(key, value)
+	if err != nil {
+		return err
+	}
+	return d.cache.Set(d.valueKey(key), value, timeout)
+}
+
+// Load retrieves an item, reassembling it from its chunks when its storage details have a chunk manifest.
+// Missing chunks or a checksum mismatch are reported as not found
+func (d *Dao) Load(key string, storageDetails *StorageDetails) (string, bool, error) {
+	d, err := d.resolve()
+	if err != nil {
+		return empty, false, err
+	}
+
+	if storageDetails.Chunks <= 0 {
+		return d.Get(key)
+	}
+
+	chunks := make([]string, storageDetails.Chunks)
+	for index := range chunks {
+		chunk, found, err := d.cache.Get(d.chunkKey(key, index))
+		if err != nil || !found {
+			return empty, false, err
+		}
+		chunks[index] = chunk
+	}
+
+	value := strings.Join(chunks, empty)
+	if checksumValue(value) != storageDetails.Checksum {
+		return empty, false, nil
+	}
+
+	value, err = d.decode(key, value)
+	if err != nil {
+		return empty, false, err
+	}
+	return value, true, nil
+}
+
+// Store stores an item and its storage details. Values larger than the chunk size are split into chunks
+// whose manifest is recorded in the storage details
+func (d *Dao) Store(key string, value string, storageDetails *StorageDetails) error {
+	d, err := d.resolve()
+	if err != nil {
+		return err
+	}
+
+	value, err = d.encode(key, value)
+	if err != nil {
+		return err
+	}
+
+	chunkSize := d.chunkSize()
+	if chunkSize <= 0 || len(value) <= chunkSize {
+		storageDetails.Chunks, storageDetails.Checksum = 0, empty
+		err = d.SetStorageDetails(key, storageDetails)
+		if err != nil {
+			return err
+		}
+
+		err = d.cache.Set(d.valueKey(key), value, storageDetails.UseBy)
+		if err != nil {
+			return err
+		}
+		return d.tag(key, storageDetails)
+	}
+
+	chunks := splitValue(value, chunkSize)
+	for index, chunk := range chunks {
+		err = d.cache.Set(d.chunkKey(key, index), chunk, storageDetails.UseBy)
+		if err != nil {
+			return err
+		}
+	}
+
+	storageDetails.Chunks, storageDetails.Checksum = len(chunks), checksumValue(value)
+	err = d.SetStorageDetails(key, storageDetails)
+	if err != nil {
+		return err
+	}
+
+	err = d.cache.Remove(d.valueKey(key))
+	if err != nil {
+		return err
+	}
+	return d.tag(key, storageDetails)
 }
 
 // SetStorageDetails stores a key's defaults
 func (d *Dao) SetStorageDetails(key string, storageDetails *StorageDetails) error {
-	storageDetails.Timestamp = time.Now().UTC()
-	timestampString, err := xconversions.Stringify(storageDetails)
+	d, err := d.resolve()
 	if err != nil {
 		return err
 	}
 
-	configKey := fmt.Sprintf(configKeyFormat, key)
-	return d.cache.Set(configKey, timestampString, 0)
+	storageDetails.Timestamp = time.Now().UTC()
+	return d.storeStorageDetails(key, storageDetails)
+}
+
+// Touch stores an item's storage details and extends the expiration of its value or chunks to its "Use By" duration.
+// It returns whether the value was found
+func (d *Dao) Touch(key string, storageDetails *StorageDetails) (bool, error) {
+	d, err := d.resolve()
+	if err != nil {
+		return false, err
+	}
+
+	expirer, ok := d.cache.(Expirer)
+	if !ok {
+		return false, errors.New(expirationNotSupportedError)
+	}
+
+	keys := []string{d.valueKey(key)}
+	if storageDetails.Chunks > 0 {
+		keys = make([]string, storageDetails.Chunks)
+		for index := range keys {
+			keys[index] = d.chunkKey(key, index)
+		}
+	}
+
+	for _, valueKey := range keys {
+		found, err := expirer.Expire(valueKey, storageDetails.UseBy)
+		if err != nil || !found {
+			return false, err
+		}
+	}
+
+	err = d.SetStorageDetails(key, storageDetails)
+	if err != nil {
+		return false, err
+	}
+	return true, d.tag(key, storageDetails)
+}
+
+// Invalidate marks an item as past one of its durations while keeping its value and timestamp
+func (d *Dao) Invalidate(key string, invalidation Invalidation) (bool, error) {
+	d, err := d.resolve()
+	if err != nil {
+		return false, err
+	}
+
+	storageDetails, found, err := d.GetStorageDetails(key)
+	if err != nil || !found {
+		return false, err
+	}
+
+	return true, d.invalidate(key, storageDetails, invalidation)
+}
+
+// InvalidateTag invalidates every item whose storage details have a tag, and removes the tag's members that no longer do
+func (d *Dao) InvalidateTag(tag string, invalidation Invalidation) (int, error) {
+	d, err := d.resolve()
+	if err != nil {
+		return 0, err
+	}
+
+	tagger, ok := d.cache.(Tagger)
+	if !ok {
+		return 0, errors.New(tagsNotSupportedError)
+	}
+
+	tagKey := d.tagKey(tag)
+	keys, err := tagger.Members(tagKey)
+	if err != nil {
+		return 0, err
+	}
+
+	invalidated := 0
+	staleKeys := []string{}
+	for _, key := range keys {
+		storageDetails, found, err := d.GetStorageDetails(key)
+		if err != nil {
+			_, ok := err.(*CorruptMetadataError)
+			if !ok {
+				return invalidated, err
+			}
+		}
+
+		if !found || !hasTag(storageDetails, tag) {
+			staleKeys = append(staleKeys, key)
+			continue
+		}
+
+		err = d.invalidate(key, storageDetails, invalidation)
+		if err != nil {
+			return invalidated, err
+		}
+		invalidated++
+	}
+
+	if len(staleKeys) == 0 {
+		return invalidated, nil
+	}
+	return invalidated, tagger.RemoveMembers(tagKey, staleKeys...)
 }
 
 // GetStorageDetails retrieves a key's storage details
 func (d *Dao) GetStorageDetails(key string) (*StorageDetails, bool, error) {
-	configKey := fmt.Sprintf(configKeyFormat, key)
-	configString, found, err := d.cache.Get(configKey)
+	d, err := d.resolve()
+	if err != nil {
+		return nil, false, err
+	}
+
+	configString, found, err := d.cache.Get(d.storageDetailsKey(key))
 	if err != nil {
 		return nil, false, err
 	}
@@ -49,22 +282,169 @@ func (d *Dao) GetStorageDetails(key string) (*StorageDetails, bool, error) {
 		return nil, false, nil
 	}
 
-	var storageDetails *StorageDetails
-	err = xconversions.Structify(configString, &storageDetails)
+	storageDetails, err := parseStorageDetails(key, configString)
 	if err != nil {
 		return nil, false, err
 	}
 	return storageDetails, true, nil
 }
 
+// Inspect retrieves an item's storage details and the sizes and remaining time to live of what is stored for it
+func (d *Dao) Inspect(key string) (*Inspection, bool, error) {
+	d, err := d.resolve()
+	if err != nil {
+		return nil, false, err
+	}
+
+	inspection := &Inspection{}
+	_, inspection.TTLSupported = d.cache.(Inspector)
+
+	configString, found, err := d.cache.Get(d.storageDetailsKey(key))
+	if err != nil {
+		return nil, false, err
+	}
+
+	valueKeys := []string{d.valueKey(key)}
+	if found {
+		inspection.StorageDetails, err = parseStorageDetails(key, configString)
+		if err != nil {
+			return nil, false, err
+		}
+
+		inspection.StorageDetailsSize = len(configString)
+		inspection.StorageDetailsTTL, err = d.ttl(d.storageDetailsKey(key))
+		if err != nil {
+			return nil, false, err
+		}
+
+		if inspection.StorageDetails.Chunks > 0 {
+			valueKeys = make([]string, inspection.StorageDetails.Chunks)
+			for index := range valueKeys {
+				valueKeys[index] = d.chunkKey(key, index)
+			}
+		}
+	}
+
+	inspection.ValueSize, inspection.ValueFound, err = d.size(valueKeys...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if inspection.ValueFound {
+		inspection.ValueTTL, err = d.ttl(valueKeys[0])
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	if inspection.StorageDetails == nil && !inspection.ValueFound {
+		return nil, false, nil
+	}
+	return inspection, true, nil
+}
+
 // Remove an item
 func (d *Dao) Remove(key string) error {
-	timestampKey := fmt.Sprintf(configKeyFormat, key)
-	err := d.cache.Remove(key)
+	d, err := d.resolve()
+	if err != nil {
+		return err
+	}
+
+	storageDetails, found, err := d.GetStorageDetails(key)
+	if err != nil {
+		_, ok := err.(*CorruptMetadataError)
+		if !ok {
+			return err
+		}
+	}
+
+	if found {
+		for index := 0; index < storageDetails.Chunks; index++ {
+			err = d.cache.Remove(d.chunkKey(key, index))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = d.cache.Remove(d.valueKey(key))
 	if err != nil {
 		return err
 	}
-	return d.cache.Remove(timestampKey)
+	return d.cache.Remove(d.storageDetailsKey(key))
+}
+
+// Sweep removes storage details whose items are gone and whose grace period has passed
+func (d *Dao) Sweep(batchSize int) (int, error) {
+	d, err := d.resolve()
+	if err != nil {
+		return 0, err
+	}
+
+	sweeper, ok := d.cache.(Sweeper)
+	if !ok {
+		return 0, errors.New(sweepNotSupportedError)
+	}
+
+	if batchSize <= 0 {
+		batchSize = defaultSweepBatchSize
+	}
+
+	pattern := globEscaper.Replace(d.namespacePrefix()) + d.defaults.KeyLayout.StorageDetailsPattern()
+	removed := 0
+	batch := make([]string, 0, batchSize)
+
+	var cursor int64
+	for {
+		nextCursor, configKeys, err := sweeper.Scan(cursor, pattern)
+		if err != nil {
+			return removed, err
+		}
+
+		for _, configKey := range configKeys {
+			key, ok := d.parseStorageDetailsKey(configKey)
+			if !ok {
+				continue
+			}
+
+			orphaned, err := d.isOrphaned(key)
+			if err != nil {
+				return removed, err
+			}
+
+			if !orphaned {
+				continue
+			}
+
+			batch = append(batch, configKey)
+			if len(batch) < batchSize {
+				continue
+			}
+
+			err = sweeper.RemoveAll(batch...)
+			if err != nil {
+				return removed, err
+			}
+
+			removed += len(batch)
+			batch = batch[:0]
+		}
+
+		if nextCursor == 0 {
+			break
+		}
+		cursor = nextCursor
+	}
+
+	if len(batch) == 0 {
+		return removed, nil
+	}
+
+	err = sweeper.RemoveAll(batch...)
+	if err != nil {
+		return removed, err
+	}
+	return removed + len(batch), nil
 }
 
 // Ping pings redis
@@ -77,6 +457,234 @@ func (d *Dao) Close() error {
 	return d.cache.Close()
 }
 
-func newDao(cache Cache) *Dao {
-	return &Dao{cache: cache}
+func (d *Dao) valueKey(key string) string {
+	return d.namespacePrefix() + d.defaults.KeyLayout.ValueKey(key)
+}
+
+func (d *Dao) chunkKey(key string, index int) string {
+	return d.valueKey(key) + chunkSeparator + strconv.Itoa(index)
+}
+
+func (d *Dao) storageDetailsKey(key string) string {
+	return d.namespacePrefix() + d.defaults.KeyLayout.StorageDetailsKey(key)
+}
+
+func (d *Dao) parseStorageDetailsKey(storageDetailsKey string) (string, bool) {
+	prefix := d.namespacePrefix()
+	if !strings.HasPrefix(storageDetailsKey, prefix) {
+		return empty, false
+	}
+	return d.defaults.KeyLayout.ParseStorageDetailsKey(strings.TrimPrefix(storageDetailsKey, prefix))
+}
+
+// namespacePrefix returns the prefix of every key. With generations, it ends with the generation resolved for the operation,
+// so that no item key starts like the generation counter
+func (d *Dao) namespacePrefix() string {
+	if d.prefix != nil {
+		return *d.prefix
+	}
+
+	if len(d.defaults.Namespace) == 0 {
+		return empty
+	}
+	return d.defaults.Namespace + namespaceSeparator
+}
+
+// resolve returns a copy of the Dao whose keys share the generation read once,
+// so that an operation never stores its keys under different generations
+func (d *Dao) resolve() (*Dao, error) {
+	if d.generations == nil || d.prefix != nil {
+		return d, nil
+	}
+
+	generation, err := d.generations.current(d.defaults.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := d.namespacePrefix() + strconv.FormatInt(generation, 10) + namespaceSeparator
+	return &Dao{cache: d.cache, defaults: d.defaults, generations: d.generations, prefix: &prefix}, nil
+}
+
+// size returns the total size of keys, or that they were not found when any of them is missing
+func (d *Dao) size(keys ...string) (int, bool, error) {
+	size := 0
+	for _, key := range keys {
+		value, found, err := d.cache.Get(key)
+		if err != nil || !found {
+			return 0, false, err
+		}
+		size += len(value)
+	}
+	return size, true, nil
+}
+
+func (d *Dao) ttl(key string) (time.Duration, error) {
+	inspector, ok := d.cache.(Inspector)
+	if !ok {
+		return 0, nil
+	}
+
+	ttl, _, err := inspector.TTL(key)
+	return ttl, err
+}
+
+func (d *Dao) encode(key string, value string) (string, error) {
+	policy := d.defaults.policy(key)
+	value, err := compressValue(value, policy.Compressor, policy.CompressionThreshold)
+	if err != nil {
+		return empty, err
+	}
+
+	if d.defaults.KeyProvider == nil {
+		return value, nil
+	}
+	return encryptValue(key, value, d.defaults.KeyProvider)
+}
+
+func (d *Dao) decode(key string, value string) (string, error) {
+	value, err := decryptValue(key, value, d.defaults.KeyProvider)
+	if err != nil {
+		return empty, err
+	}
+	compressors := append([]Compressor{d.defaults.policy(key).Compressor, d.defaults.Compressor}, builtinCompressors...)
+	return decompressValue(value, compressors...)
+}
+
+// chunkSize returns the chunk size, which is capped by the maximum value size when oversized values are chunked
+func (d *Dao) chunkSize() int {
+	chunkSize := d.defaults.ChunkSize
+	maxValueSize := d.defaults.MaxValueSize
+	if d.defaults.OversizedValuePolicy != ChunkOversizedValues || maxValueSize <= 0 {
+		return chunkSize
+	}
+
+	if chunkSize <= 0 || chunkSize > maxValueSize {
+		return maxValueSize
+	}
+	return chunkSize
+}
+
+func (d *Dao) invalidate(key string, storageDetails *StorageDetails, invalidation Invalidation) error {
+	if invalidation > storageDetails.Invalidation {
+		storageDetails.Invalidation = invalidation
+	}
+	return d.storeStorageDetails(key, storageDetails)
+}
+
+// checkTags returns an error when storage details have tags that the cache cannot store
+func (d *Dao) checkTags(storageDetails *StorageDetails) error {
+	if len(storageDetails.Tags) == 0 {
+		return nil
+	}
+
+	_, ok := d.cache.(Tagger)
+	if !ok {
+		return errors.New(tagsNotSupportedError)
+	}
+	return nil
+}
+
+func (d *Dao) tag(key string, storageDetails *StorageDetails) error {
+	if len(storageDetails.Tags) == 0 {
+		return nil
+	}
+
+	tagger, ok := d.cache.(Tagger)
+	if !ok {
+		return errors.New(tagsNotSupportedError)
+	}
+
+	timeout := d.storageDetailsTimeout(key, storageDetails)
+	for _, tag := range storageDetails.Tags {
+		err := tagger.AddMembers(d.tagKey(tag), timeout, key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Dao) tagKey(tag string) string {
+	return d.namespacePrefix() + tagPrefix + escapeKey(tag)
+}
+
+func (d *Dao) storeStorageDetails(key string, storageDetails *StorageDetails) error {
+	storageDetails.Version = storageDetailsVersion
+	configString, err := xconversions.Stringify(storageDetails)
+	if err != nil {
+		return err
+	}
+	return d.cache.Set(d.storageDetailsKey(key), configString, d.storageDetailsTimeout(key, storageDetails))
+}
+
+func (d *Dao) storageDetailsTimeout(key string, storageDetails *StorageDetails) time.Duration {
+	if storageDetails.UseBy <= 0 {
+		return NoExpiration
+	}
+	return storageDetails.UseBy + d.defaults.policy(key).GracePeriod
+}
+
+// isOrphaned returns whether storage details written by fridge outlived their value past their "Use By" and grace period.
+// Keys that merely match the layout, such as undecodable or foreign ones, are never orphaned
+func (d *Dao) isOrphaned(key string) (bool, error) {
+	_, found, err := d.cache.Get(d.valueKey(key))
+	if err != nil {
+		return false, err
+	}
+
+	if found {
+		return false, nil
+	}
+
+	storageDetails, found, err := d.GetStorageDetails(key)
+	if err != nil {
+		_, ok := err.(*CorruptMetadataError)
+		if !ok {
+			return false, err
+		}
+		return false, nil
+	}
+
+	if !found || storageDetails.Version == 0 || storageDetails.Timestamp.IsZero() {
+		return false, nil
+	}
+
+	deadline := storageDetails.Timestamp.Add(storageDetails.UseBy + d.defaults.policy(key).GracePeriod)
+	return time.Now().UTC().After(deadline), nil
+}
+
+func hasTag(storageDetails *StorageDetails, tag string) bool {
+	for _, storedTag := range storageDetails.Tags {
+		if storedTag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func parseStorageDetails(key string, configString string) (*StorageDetails, error) {
+	var storageDetails *StorageDetails
+	err := xconversions.Structify(configString, &storageDetails)
+	if err != nil {
+		return nil, &CorruptMetadataError{Key: key, Err: err}
+	}
+
+	if storageDetails == nil {
+		return nil, &CorruptMetadataError{Key: key, Err: errors.New(nullStorageDetailsError)}
+	}
+
+	if storageDetails.Version > storageDetailsVersion {
+		err = fmt.Errorf(unsupportedVersionErrorFormat, storageDetails.Version)
+		return nil, &CorruptMetadataError{Key: key, Err: err}
+	}
+	return storageDetails, nil
+}
+
+func newDao(cache Cache, defaults *Defaults) *Dao {
+	dao := &Dao{cache: cache, defaults: defaults}
+	if defaults.Generations {
+		dao.generations = newGenerations(cache, defaults.GenerationRefreshInterval)
+	}
+	return dao
 }
@@ -0,0 +1,397 @@
+package fridge
+
+import (
+	"compress/gzip"
+	"github.com/stretchr/testify/assert"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryCache struct {
+	mutex    sync.Mutex
+	memory   map[string]string
+	timeouts map[string]time.Duration
+	sets     map[string]map[string]bool
+}
+
+func (c *memoryCache) Get(key string) (string, bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	value, ok := c.memory[key]
+	return value, ok, nil
+}
+
+func (c *memoryCache) Set(key string, value string, timeout time.Duration) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.memory[key] = value
+	c.timeouts[key] = timeout
+	return nil
+}
+
+func (c *memoryCache) Remove(key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.memory, key)
+	return nil
+}
+
+func (c *memoryCache) Scan(cursor int64, pattern string) (int64, []string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	keys := []string{}
+	for key := range c.memory {
+		matched, err := path.Match(pattern, key)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if matched {
+			keys = append(keys, key)
+		}
+	}
+	return 0, keys, nil
+}
+
+func (c *memoryCache) RemoveAll(keys ...string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, key := range keys {
+		delete(c.memory, key)
+	}
+	return nil
+}
+
+func (c *memoryCache) TTL(key string) (time.Duration, bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	_, ok := c.memory[key]
+	return c.timeouts[key], ok, nil
+}
+
+func (c *memoryCache) AddMembers(key string, timeout time.Duration, members ...string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	set, ok := c.sets[key]
+	if !ok {
+		set = make(map[string]bool)
+		c.sets[key] = set
+		c.timeouts[key] = timeout
+	}
+
+	for _, member := range members {
+		set[member] = true
+	}
+
+	if timeout == NoExpiration || (c.timeouts[key] != NoExpiration && c.timeouts[key] < timeout) {
+		c.timeouts[key] = timeout
+	}
+	return nil
+}
+
+func (c *memoryCache) Members(key string) ([]string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	members := []string{}
+	for member := range c.sets[key] {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+func (c *memoryCache) RemoveMembers(key string, members ...string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, member := range members {
+		delete(c.sets[key], member)
+	}
+	return nil
+}
+
+func (c *memoryCache) Expire(key string, timeout time.Duration) (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	_, ok := c.memory[key]
+	if ok {
+		c.timeouts[key] = timeout
+	}
+	return ok, nil
+}
+
+func (c *memoryCache) Increment(key string) (int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	value, _ := strconv.ParseInt(c.memory[key], 10, 64)
+	c.memory[key] = strconv.FormatInt(value+1, 10)
+	return value + 1, nil
+}
+
+func (c *memoryCache) Ping() error {
+	return nil
+}
+
+func (c *memoryCache) Close() error {
+	return nil
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{
+		memory:   make(map[string]string),
+		timeouts: make(map[string]time.Duration),
+		sets:     make(map[string]map[string]bool),
+	}
+}
+
+func TestDao_StorageDetails(t *testing.T) {
+	dao := newDao(newMemoryCache(), newDefaults())
+
+	err := dao.SetStorageDetails("food", &StorageDetails{BestBy: time.Second, UseBy: time.Minute})
+	assert.Nil(t, err)
+
+	storageDetails, found, err := dao.GetStorageDetails("food")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, storageDetails.Version, storageDetailsVersion)
+	assert.Equal(t, storageDetails.BestBy, time.Second)
+	assert.Equal(t, storageDetails.UseBy, time.Minute)
+}
+
+func TestDao_StorageDetailsTimeout(t *testing.T) {
+	cache := newMemoryCache()
+	dao := newDao(cache, newDefaults(WithGracePeriod(time.Hour)))
+
+	err := dao.SetStorageDetails("food", &StorageDetails{BestBy: time.Second, UseBy: time.Minute})
+	assert.Nil(t, err)
+	assert.Equal(t, cache.timeouts["food.config"], time.Hour+time.Minute)
+
+	err = dao.SetStorageDetails("food", &StorageDetails{})
+	assert.Nil(t, err)
+	assert.Equal(t, cache.timeouts["food.config"], time.Duration(0))
+}
+
+func TestDao_Sweep(t *testing.T) {
+	cache := newMemoryCache()
+	dao := newDao(cache, newDefaults(WithGracePeriod(0)))
+
+	storageDetails := &StorageDetails{UseBy: time.Nanosecond}
+	assert.Nil(t, dao.SetStorageDetails("food1", storageDetails))
+	assert.Nil(t, dao.SetStorageDetails("food2", storageDetails))
+	assert.Nil(t, dao.Set("food2", "Milk", 0))
+	assert.Nil(t, dao.SetStorageDetails("food3", &StorageDetails{UseBy: time.Hour}))
+	cache.memory["food4.config"] = "Pizza"
+	cache.memory["nginx.config"] = "server { listen 80; }"
+	cache.memory["app.config"] = `{"debug":true}`
+	cache.memory["food5.config"] = `{"Version":1,"UseBy":1}`
+
+	removed, err := dao.Sweep(1)
+	assert.Nil(t, err)
+	assert.Equal(t, removed, 1)
+
+	_, found, _ := cache.Get("food1.config")
+	assert.False(t, found)
+
+	_, found, _ = cache.Get("food2.config")
+	assert.True(t, found)
+
+	_, found, _ = cache.Get("food3.config")
+	assert.True(t, found)
+
+	for _, key := range []string{"food4.config", "nginx.config", "app.config", "food5.config"} {
+		_, found, _ = cache.Get(key)
+		assert.True(t, found)
+	}
+}
+
+func TestDao_CorruptStorageDetails(t *testing.T) {
+	cache := newMemoryCache()
+	dao := newDao(cache, newDefaults())
+
+	cache.memory["food.config"] = "Pizza"
+
+	_, found, err := dao.GetStorageDetails("food")
+	assert.False(t, found)
+	assert.IsType(t, &CorruptMetadataError{}, err)
+
+	cache.memory["food.config"] = `{"Version":99}`
+
+	_, found, err = dao.GetStorageDetails("food")
+	assert.False(t, found)
+	assert.IsType(t, &CorruptMetadataError{}, err)
+}
+
+func TestDao_Namespace(t *testing.T) {
+	cache := newMemoryCache()
+	dao := newDao(cache, newDefaults(WithNamespace("service"), WithGracePeriod(0)))
+
+	assert.Nil(t, dao.Set("food", "Pizza", 0))
+	assert.Nil(t, dao.SetStorageDetails("food", &StorageDetails{UseBy: time.Nanosecond}))
+
+	_, found, _ := cache.Get("service:food")
+	assert.True(t, found)
+
+	_, found, _ = cache.Get("service:food.config")
+	assert.True(t, found)
+
+	assert.Nil(t, dao.Remove("food"))
+	assert.Nil(t, dao.SetStorageDetails("food", &StorageDetails{UseBy: time.Nanosecond}))
+	assert.Nil(t, dao.Set("milk", "Milk", 0))
+	assert.Nil(t, dao.SetStorageDetails("milk", &StorageDetails{}))
+	cache.memory["food.config"] = "Pizza"
+
+	removed, err := dao.Sweep(10)
+	assert.Nil(t, err)
+	assert.Equal(t, removed, 1)
+
+	_, found, _ = cache.Get("food.config")
+	assert.True(t, found)
+
+	_, found, _ = cache.Get("service:milk.config")
+	assert.True(t, found)
+}
+
+func TestDao_Compression(t *testing.T) {
+	cache := newMemoryCache()
+	dao := newDao(cache, newDefaults(WithCompression(NewGzipCompressor(gzip.BestSpeed), 10)))
+
+	value := strings.Repeat("Pizza", 100)
+	assert.Nil(t, dao.Set("food", value, 0))
+	assert.True(t, len(cache.memory["food"]) < len(value))
+
+	cachedValue, found, err := dao.Get("food")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, cachedValue, value)
+
+	dao = newDao(cache, newDefaults())
+
+	cachedValue, found, err = dao.Get("food")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, cachedValue, value)
+}
+
+func TestDao_Encryption(t *testing.T) {
+	cache := newMemoryCache()
+	keyProvider := NewStaticKeyProvider("first", map[string][]byte{"first": firstKey})
+	dao := newDao(cache, newDefaults(WithEncryption(keyProvider), WithCompression(NewGzipCompressor(gzip.BestSpeed), 10)))
+
+	value := strings.Repeat("Pizza", 100)
+	assert.Nil(t, dao.Set("food", value, 0))
+	assert.Nil(t, dao.SetStorageDetails("food", &StorageDetails{UseBy: time.Minute}))
+	assert.NotContains(t, cache.memory["food"], "Pizza")
+	assert.Contains(t, cache.memory["food.config"], "UseBy")
+
+	cachedValue, found, err := dao.Get("food")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, cachedValue, value)
+}
+
+func TestDao_Chunking(t *testing.T) {
+	cache := newMemoryCache()
+	dao := newDao(cache, newDefaults(WithChunking(10)))
+
+	value := strings.Repeat("Pizza", 9)
+	assert.Nil(t, dao.Set("food", "Milk", 0))
+
+	storageDetails := &StorageDetails{UseBy: time.Minute}
+	assert.Nil(t, dao.Store("food", value, storageDetails))
+	assert.Equal(t, storageDetails.Chunks, 5)
+	assert.NotContains(t, cache.memory, "food")
+	assert.Equal(t, cache.memory["food:chunk:4"], "Pizza")
+	assert.Equal(t, cache.timeouts["food:chunk:0"], time.Minute)
+
+	storageDetails, found, err := dao.GetStorageDetails("food")
+	assert.Nil(t, err)
+	assert.True(t, found)
+
+	cachedValue, found, err := dao.Load("food", storageDetails)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, cachedValue, value)
+
+	cache.memory["food:chunk:2"] = "PizzaMilk!"
+
+	_, found, err = dao.Load("food", storageDetails)
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	assert.Nil(t, dao.Remove("food"))
+	assert.Empty(t, cache.memory)
+}
+
+func TestDao_Touch(t *testing.T) {
+	cache := newMemoryCache()
+	dao := newDao(cache, newDefaults(WithChunking(10)))
+
+	storageDetails := &StorageDetails{UseBy: time.Minute}
+	assert.Nil(t, dao.Store("food", strings.Repeat("Pizza", 3), storageDetails))
+
+	storageDetails.UseBy = time.Hour
+	found, err := dao.Touch("food", storageDetails)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, cache.timeouts["food:chunk:0"], time.Hour)
+	assert.Equal(t, cache.timeouts["food:chunk:1"], time.Hour)
+
+	delete(cache.memory, "food:chunk:1")
+
+	found, err = dao.Touch("food", storageDetails)
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
+
+func TestDao_ChunkSize(t *testing.T) {
+	assert.Equal(t, newDao(nil, newDefaults()).chunkSize(), 0)
+	assert.Equal(t, newDao(nil, newDefaults(WithChunking(10))).chunkSize(), 10)
+	assert.Equal(t, newDao(nil, newDefaults(WithChunking(10), WithMaxValueSize(5, RejectOversizedValues))).chunkSize(), 10)
+	assert.Equal(t, newDao(nil, newDefaults(WithMaxValueSize(5, ChunkOversizedValues))).chunkSize(), 5)
+	assert.Equal(t, newDao(nil, newDefaults(WithChunking(3), WithMaxValueSize(5, ChunkOversizedValues))).chunkSize(), 3)
+}
+
+func TestDao_Tags(t *testing.T) {
+	cache := newMemoryCache()
+	dao := newDao(cache, newDefaults(WithNamespace("service"), WithGracePeriod(time.Minute)))
+
+	assert.Nil(t, dao.Store("food", "Pizza", &StorageDetails{UseBy: time.Hour, Tags: []string{"customer:1"}}))
+	assert.Nil(t, dao.Store("drink", "Milk", &StorageDetails{UseBy: 2 * time.Hour, Tags: []string{"customer:1", "customer:2"}}))
+	assert.Nil(t, dao.Store("dessert", "Cake", &StorageDetails{UseBy: time.Hour, Tags: []string{"customer:1"}}))
+
+	assert.Equal(t, cache.timeouts["service:tag:customer%3A1"], 2*time.Hour+time.Minute)
+
+	members, _ := cache.Members("service:tag:customer%3A1")
+	assert.Equal(t, members, []string{"dessert", "drink", "food"})
+
+	assert.Nil(t, dao.Store("dessert", "Cake", &StorageDetails{UseBy: time.Hour}))
+	assert.Nil(t, dao.Remove("food"))
+
+	invalidated, err := dao.InvalidateTag("customer:1", InvalidateUseBy)
+	assert.Nil(t, err)
+	assert.Equal(t, invalidated, 1)
+
+	members, _ = cache.Members("service:tag:customer%3A1")
+	assert.Equal(t, members, []string{"drink"})
+
+	storageDetails, _, _ := dao.GetStorageDetails("drink")
+	assert.Equal(t, storageDetails.Invalidation, InvalidateUseBy)
+
+	storageDetails, _, _ = dao.GetStorageDetails("dessert")
+	assert.Equal(t, storageDetails.Invalidation, Invalidation(0))
+}
@@ -5,10 +5,39 @@ import (
 )
 
 const (
-	defaultBestBy = time.Hour
-	defaultUseBy  = 24 * time.Hour
+	defaultBestBy      = time.Hour
+	defaultUseBy       = 24 * time.Hour
+	defaultGracePeriod = 24 * time.Hour
 )
 
+const (
+	// FailOnCorruptMetadata returns the decoding error to the caller
+	FailOnCorruptMetadata CorruptMetadataPolicy = iota
+
+	// IgnoreCorruptMetadata treats the item as not found and restocks it
+	IgnoreCorruptMetadata
+
+	// RemoveCorruptMetadata removes the item and restocks it
+	RemoveCorruptMetadata
+)
+
+const (
+	// RejectOversizedValues returns an OversizedValueError
+	RejectOversizedValues OversizedValuePolicy = iota
+
+	// SkipOversizedValues returns the value without caching it
+	SkipOversizedValues
+
+	// ChunkOversizedValues stores the value in chunks no larger than the maximum value size
+	ChunkOversizedValues
+)
+
+// OversizedValuePolicy decides what happens to values that exceed the maximum value size
+type OversizedValuePolicy int
+
+// CorruptMetadataPolicy decides what happens to items whose storage details cannot be decoded
+type CorruptMetadataPolicy int
+
 // DefaultsOption an option for default values
 type DefaultsOption func(*Defaults)
 
@@ -20,16 +49,108 @@ func WithDefaultDurations(bestBy time.Duration, useBy time.Duration) DefaultsOpt
 	}
 }
 
+// WithGracePeriod sets how long storage details outlive their item's "Use By" duration
+func WithGracePeriod(gracePeriod time.Duration) DefaultsOption {
+	return func(defaults *Defaults) {
+		defaults.GracePeriod = gracePeriod
+	}
+}
+
+// WithNamespace sets a prefix for every key stored in the cache
+func WithNamespace(namespace string) DefaultsOption {
+	return func(defaults *Defaults) {
+		defaults.Namespace = namespace
+	}
+}
+
+// WithKeyLayout sets how keys map to the cache keys of values and storage details
+func WithKeyLayout(keyLayout KeyLayout) DefaultsOption {
+	return func(defaults *Defaults) {
+		defaults.KeyLayout = keyLayout
+	}
+}
+
+// WithCompression compresses values whose size is at or above the threshold in bytes
+func WithCompression(compressor Compressor, threshold int) DefaultsOption {
+	return func(defaults *Defaults) {
+		defaults.Compressor = compressor
+		defaults.CompressionThreshold = threshold
+	}
+}
+
+// WithEncryption encrypts values with AES-GCM using keys from the key provider. Storage details are not encrypted
+func WithEncryption(keyProvider KeyProvider) DefaultsOption {
+	return func(defaults *Defaults) {
+		defaults.KeyProvider = keyProvider
+	}
+}
+
+// WithMaxValueSize sets the maximum size in bytes of a value and the policy for values that exceed it
+func WithMaxValueSize(maxValueSize int, policy OversizedValuePolicy) DefaultsOption {
+	return func(defaults *Defaults) {
+		defaults.MaxValueSize = maxValueSize
+		defaults.OversizedValuePolicy = policy
+	}
+}
+
+// WithChunking splits values that are larger than the chunk size in bytes into several cache keys
+func WithChunking(chunkSize int) DefaultsOption {
+	return func(defaults *Defaults) {
+		defaults.ChunkSize = chunkSize
+	}
+}
+
+// WithEarlyRefresh lets reads of fresh items restock them early with a chance that rises as their "Best By"
+// duration approaches, scaled by beta and by how long their last restock took. 1 is a good default for beta
+func WithEarlyRefresh(beta float64) DefaultsOption {
+	return func(defaults *Defaults) {
+		defaults.EarlyRefreshBeta = beta
+	}
+}
+
+// WithGenerations adds the generation of the namespace to every key, so that BumpGeneration invalidates the namespace at once.
+// Generations are reloaded from the cache once the refresh interval has passed, or on every access when it is 0,
+// which costs an extra read from the cache per operation. Operations fail while no generation could be loaded yet
+func WithGenerations(refreshInterval time.Duration) DefaultsOption {
+	return func(defaults *Defaults) {
+		defaults.Generations = true
+		defaults.GenerationRefreshInterval = refreshInterval
+	}
+}
+
+// WithCorruptMetadataPolicy sets the policy for storage details that cannot be decoded
+func WithCorruptMetadataPolicy(policy CorruptMetadataPolicy) DefaultsOption {
+	return func(defaults *Defaults) {
+		defaults.CorruptMetadataPolicy = policy
+	}
+}
+
 // Defaults configuration for the fridge client
 type Defaults struct {
-	BestBy time.Duration
-	UseBy  time.Duration
+	BestBy                    time.Duration
+	UseBy                     time.Duration
+	GracePeriod               time.Duration
+	Namespace                 string
+	KeyLayout                 KeyLayout
+	Compressor                Compressor
+	CompressionThreshold      int
+	KeyProvider               KeyProvider
+	MaxValueSize              int
+	OversizedValuePolicy      OversizedValuePolicy
+	ChunkSize                 int
+	EarlyRefreshBeta          float64
+	Policies                  []*PolicyRule
+	Generations               bool
+	GenerationRefreshInterval time.Duration
+	CorruptMetadataPolicy     CorruptMetadataPolicy
 }
 
 func newDefaults(options ...DefaultsOption) *Defaults {
 	config := &Defaults{
-		BestBy: defaultBestBy,
-		UseBy:  defaultUseBy,
+		BestBy:      defaultBestBy,
+		UseBy:       defaultUseBy,
+		GracePeriod: defaultGracePeriod,
+		KeyLayout:   NewSuffixLayout(),
 	}
 
 	for _, option := range options {
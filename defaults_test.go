@@ -1,6 +1,7 @@
 package fridge
 
 import (
+	"compress/gzip"
 	"github.com/stretchr/testify/assert"
 	"testing"
 	"time"
@@ -22,3 +23,82 @@ func TestDefaults_Override(t *testing.T) {
 	assert.Equal(t, defaults.BestBy, time.Minute)
 	assert.Equal(t, defaults.UseBy, 2*time.Minute)
 }
+
+func TestDefaults_WithCorruptMetadataPolicy(t *testing.T) {
+	defaults := newDefaults()
+
+	assert.Equal(t, defaults.CorruptMetadataPolicy, FailOnCorruptMetadata)
+
+	defaultsOption := WithCorruptMetadataPolicy(RemoveCorruptMetadata)
+	defaultsOption(defaults)
+
+	assert.Equal(t, defaults.CorruptMetadataPolicy, RemoveCorruptMetadata)
+}
+
+func TestDefaults_WithGracePeriod(t *testing.T) {
+	defaults := newDefaults()
+
+	assert.Equal(t, defaults.GracePeriod, 24*time.Hour)
+
+	defaultsOption := WithGracePeriod(time.Minute)
+	defaultsOption(defaults)
+
+	assert.Equal(t, defaults.GracePeriod, time.Minute)
+}
+
+func TestDefaults_WithNamespace(t *testing.T) {
+	defaults := newDefaults(WithNamespace("service"))
+
+	assert.Equal(t, defaults.Namespace, "service")
+}
+
+func TestDefaults_WithKeyLayout(t *testing.T) {
+	defaults := newDefaults()
+
+	assert.IsType(t, &suffixLayout{}, defaults.KeyLayout)
+
+	defaultsOption := WithKeyLayout(NewHashTagLayout())
+	defaultsOption(defaults)
+
+	assert.IsType(t, &hashTagLayout{}, defaults.KeyLayout)
+}
+
+func TestDefaults_WithCompression(t *testing.T) {
+	defaults := newDefaults(WithCompression(NewGzipCompressor(gzip.BestSpeed), 1024))
+
+	assert.NotNil(t, defaults.Compressor)
+	assert.Equal(t, defaults.CompressionThreshold, 1024)
+}
+
+func TestDefaults_WithEncryption(t *testing.T) {
+	defaults := newDefaults(WithEncryption(NewStaticKeyProvider("first", nil)))
+
+	assert.NotNil(t, defaults.KeyProvider)
+}
+
+func TestDefaults_WithMaxValueSize(t *testing.T) {
+	defaults := newDefaults()
+
+	assert.Equal(t, defaults.MaxValueSize, 0)
+	assert.Equal(t, defaults.OversizedValuePolicy, RejectOversizedValues)
+
+	defaultsOption := WithMaxValueSize(1024, SkipOversizedValues)
+	defaultsOption(defaults)
+
+	assert.Equal(t, defaults.MaxValueSize, 1024)
+	assert.Equal(t, defaults.OversizedValuePolicy, SkipOversizedValues)
+}
+
+func TestDefaults_WithChunking(t *testing.T) {
+	defaults := newDefaults(WithChunking(1024))
+
+	assert.Equal(t, defaults.ChunkSize, 1024)
+}
+
+func TestDefaults_WithPolicy(t *testing.T) {
+	defaults := newDefaults(WithPolicy("food:", WithPolicyDurations(time.Second, time.Minute)))
+
+	assert.Len(t, defaults.Policies, 1)
+	assert.Equal(t, defaults.Policies[0].Pattern, "food:")
+	assert.Len(t, defaults.Policies[0].Options, 1)
+}
@@ -0,0 +1,137 @@
+package fridge
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	encryptedID byte = 255
+
+	maxKeyIDLength = 255
+
+	missingKeyProviderError  = "encrypted value found but no key provider is configured"
+	invalidKeyIDError        = "encryption key id must be between 1 and 255 bytes"
+	truncatedCiphertextError = "encrypted value is truncated"
+	unknownKeyIDErrorFormat  = "unknown encryption key id '%s'"
+)
+
+// KeyProvider supplies AES keys by id so that keys can be rotated while older values remain readable
+type KeyProvider interface {
+	// CurrentKey returns the id and key used to encrypt new values
+	CurrentKey() (string, []byte, error)
+
+	// Key returns the key of an id to decrypt existing values
+	Key(id string) ([]byte, error)
+}
+
+// NewStaticKeyProvider returns a key provider that encrypts with the current key id and decrypts with any of the keys.
+// Keys must be 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256
+func NewStaticKeyProvider(currentID string, keys map[string][]byte) KeyProvider {
+	return &staticKeyProvider{currentID: currentID, keys: keys}
+}
+
+type staticKeyProvider struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+func (p *staticKeyProvider) CurrentKey() (string, []byte, error) {
+	key, err := p.Key(p.currentID)
+	if err != nil {
+		return empty, nil, err
+	}
+	return p.currentID, key, nil
+}
+
+func (p *staticKeyProvider) Key(id string) ([]byte, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf(unknownKeyIDErrorFormat, id)
+	}
+	return key, nil
+}
+
+// encryptValue seals a value with AES-GCM. The result is prefixed with the marker, the encrypted id,
+// the length and value of the key id and the nonce. The item's key is authenticated along with the value
+func encryptValue(key string, value string, keyProvider KeyProvider) (string, error) {
+	keyID, encryptionKey, err := keyProvider.CurrentKey()
+	if err != nil {
+		return empty, err
+	}
+
+	if len(keyID) == 0 || len(keyID) > maxKeyIDLength {
+		return empty, errors.New(invalidKeyIDError)
+	}
+
+	aead, err := newAEAD(encryptionKey)
+	if err != nil {
+		return empty, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return empty, err
+	}
+
+	header := valueMarker + string([]byte{encryptedID, byte(len(keyID))}) + keyID + string(nonce)
+	return header + string(aead.Seal(nil, nonce, []byte(value), []byte(key))), nil
+}
+
+// decryptValue opens a value sealed by encryptValue. Values that are not encrypted are returned as is
+func decryptValue(key string, value string, keyProvider KeyProvider) (string, error) {
+	if !isEncrypted(value) {
+		return value, nil
+	}
+
+	if keyProvider == nil {
+		return empty, errors.New(missingKeyProviderError)
+	}
+
+	payload := value[valueHeaderSize:]
+	if len(payload) < 1 || len(payload) < 1+int(payload[0]) {
+		return empty, errors.New(truncatedCiphertextError)
+	}
+
+	keyID := payload[1 : 1+int(payload[0])]
+	payload = payload[1+len(keyID):]
+
+	encryptionKey, err := keyProvider.Key(keyID)
+	if err != nil {
+		return empty, err
+	}
+
+	aead, err := newAEAD(encryptionKey)
+	if err != nil {
+		return empty, err
+	}
+
+	if len(payload) < aead.NonceSize() {
+		return empty, errors.New(truncatedCiphertextError)
+	}
+
+	nonce, ciphertext := payload[:aead.NonceSize()], payload[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, []byte(nonce), []byte(ciphertext), []byte(key))
+	if err != nil {
+		return empty, err
+	}
+	return string(plaintext), nil
+}
+
+func isEncrypted(value string) bool {
+	return strings.HasPrefix(value, valueMarker) && len(value) >= valueHeaderSize && value[1] == encryptedID
+}
+
+func newAEAD(encryptionKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,72 @@
+package fridge
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var (
+	firstKey  = []byte(strings.Repeat("1", 32))
+	secondKey = []byte(strings.Repeat("2", 16))
+)
+
+func TestEncryption_RoundTrip(t *testing.T) {
+	keyProvider := NewStaticKeyProvider("first", map[string][]byte{"first": firstKey})
+
+	encrypted, err := encryptValue("food", "Pizza", keyProvider)
+	assert.Nil(t, err)
+	assert.NotContains(t, encrypted, "Pizza")
+	assert.Contains(t, encrypted, "first")
+
+	decrypted, err := decryptValue("food", encrypted, keyProvider)
+	assert.Nil(t, err)
+	assert.Equal(t, decrypted, "Pizza")
+
+	decrypted, err = decryptValue("food", "Pizza", keyProvider)
+	assert.Nil(t, err)
+	assert.Equal(t, decrypted, "Pizza")
+}
+
+func TestEncryption_Rotation(t *testing.T) {
+	oldKeyProvider := NewStaticKeyProvider("first", map[string][]byte{"first": firstKey})
+	newKeyProvider := NewStaticKeyProvider("second", map[string][]byte{"first": firstKey, "second": secondKey})
+
+	encrypted, err := encryptValue("food", "Pizza", oldKeyProvider)
+	assert.Nil(t, err)
+
+	decrypted, err := decryptValue("food", encrypted, newKeyProvider)
+	assert.Nil(t, err)
+	assert.Equal(t, decrypted, "Pizza")
+
+	encrypted, err = encryptValue("food", "Pizza", newKeyProvider)
+	assert.Nil(t, err)
+
+	_, err = decryptValue("food", encrypted, oldKeyProvider)
+	assert.NotNil(t, err)
+}
+
+func TestEncryption_Errors(t *testing.T) {
+	keyProvider := NewStaticKeyProvider("first", map[string][]byte{"first": firstKey})
+
+	encrypted, err := encryptValue("food", "Pizza", keyProvider)
+	assert.Nil(t, err)
+
+	_, err = decryptValue("drink", encrypted, keyProvider)
+	assert.NotNil(t, err)
+
+	_, err = decryptValue("food", encrypted, nil)
+	assert.NotNil(t, err)
+
+	_, err = decryptValue("food", encrypted[:len(encrypted)-1], keyProvider)
+	assert.NotNil(t, err)
+
+	_, err = decryptValue("food", encrypted[:4], keyProvider)
+	assert.NotNil(t, err)
+
+	_, err = encryptValue("food", "Pizza", NewStaticKeyProvider("missing", nil))
+	assert.NotNil(t, err)
+
+	_, err = encryptValue("food", "Pizza", NewStaticKeyProvider("short", map[string][]byte{"short": []byte("key")}))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"fmt"
+	"github.com/shomali11/fridge"
+)
+
+func main() {
+	redisCache := fridge.NewClusterCache(
+		fridge.WithClusterAddresses([]string{"localhost:7000", "localhost:7001", "localhost:7002"}))
+
+	client := fridge.NewClient(redisCache)
+	defer client.Close()
+
+	fmt.Println(client.Ping())
+}
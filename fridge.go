@@ -2,8 +2,12 @@ package fridge
 
 import (
 	"errors"
+	"fmt"
 	"github.com/shomali11/eventbus"
 	"github.com/shomali11/parallelizer"
+	"math"
+	"math/rand"
+	"sync"
 	"time"
 )
 
@@ -23,25 +27,56 @@ const (
 	// Restock is when an item was restocked with a fresher one
 	Restock = "RESTOCK"
 
-	// OutOfStock is when an item needs restocking, but no restocking function was provided
+	// OutOfStock is when an item needs restocking, but no restocking function was provided or registered
 	OutOfStock = "OUT_OF_STOCK"
 
 	// Unchanged is when the restocked item is not different from the version in the cache
 	Unchanged = "UNCHANGED"
+
+	// CorruptMetadata is when an item's storage details could not be decoded
+	CorruptMetadata = "CORRUPT_METADATA"
+
+	// Oversized is when an item's value exceeds the maximum value size
+	Oversized = "OVERSIZED"
+
+	// Warmed is when an item was loaded into the cache by warming
+	Warmed = "WARMED"
+
+	// WarmFailed is when an item could not be loaded into the cache by warming
+	WarmFailed = "WARM_FAILED"
+
+	// WarmSkipped is when warming left an item as it was, because it was still fresh or its value was oversized
+	WarmSkipped = "WARM_SKIPPED"
+
+	// EarlyRefresh is when a fresh item is restocked early in the background
+	EarlyRefresh = "EARLY_REFRESH"
+)
+
+const (
+	// NoExpiration is the timeout that stores a key without expiration
+	NoExpiration time.Duration = 0
 )
 
 const (
 	empty                 = ""
 	eventsTopic           = "fridge_events"
 	invalidDurationsError = "invalid 'best by' and 'use by' durations"
+	oversizedErrorFormat  = "value of key '%s' is %d bytes which exceeds the maximum of %d bytes"
+	notModifiedError      = "restock reported no modification but no value is cached"
 )
 
 // NewClient returns a client
 func NewClient(cache Cache, options ...DefaultsOption) *Client {
+	if layouter, ok := cache.(keyLayouter); ok {
+		options = append([]DefaultsOption{WithKeyLayout(layouter.keyLayout())}, options...)
+	}
+
+	defaults := newDefaults(options...)
 	client := &Client{
-		defaults: newDefaults(options...),
-		dao:      newDao(cache),
-		group:    parallelizer.NewGroup(),
+		defaults:  defaults,
+		dao:       newDao(cache, defaults),
+		group:     parallelizer.NewGroup(),
+		schedules: make(map[string]*schedule),
 	}
 
 	bus := eventbus.NewClient()
@@ -66,7 +101,7 @@ type Cache interface {
 	// Get a value by key
 	Get(key string) (string, bool, error)
 
-	// Set a key value pair
+	// Set a key value pair that expires after a timeout, or never when the timeout is NoExpiration
 	Set(key string, value string, timeout time.Duration) error
 
 	// Remove a key
@@ -79,83 +114,174 @@ type Cache interface {
 	Close() error
 }
 
+// Sweeper is an optional Fridge cache interface used to clean up orphaned storage details
+type Sweeper interface {
+	// Scan keys matching a pattern starting from a cursor
+	Scan(cursor int64, pattern string) (int64, []string, error)
+
+	// RemoveAll removes a batch of keys
+	RemoveAll(keys ...string) error
+}
+
+// Inspector is an optional Fridge cache interface used to inspect how long keys have left to live
+type Inspector interface {
+	// TTL returns the remaining time to live of a key, or NoExpiration when it does not expire
+	TTL(key string) (time.Duration, bool, error)
+}
+
+// Tagger is an optional Fridge cache interface used to maintain the members of tags
+type Tagger interface {
+	// AddMembers adds members to a set, which expires after the longest timeout it was given, or never when given NoExpiration
+	AddMembers(key string, timeout time.Duration, members ...string) error
+
+	// Members returns the members of a set
+	Members(key string) ([]string, error)
+
+	// RemoveMembers removes members from a set
+	RemoveMembers(key string, members ...string) error
+}
+
+// Expirer is an optional Fridge cache interface used to extend items without rewriting their values
+type Expirer interface {
+	// Expire sets when a key expires, or that it never does when given NoExpiration, and returns whether the key exists
+	Expire(key string, timeout time.Duration) (bool, error)
+}
+
+// Counter is an optional Fridge cache interface used to maintain generation counters
+type Counter interface {
+	// Increment increments a counter and returns its new value
+	Increment(key string) (int64, error)
+}
+
+// keyLayouter is implemented by caches that need a specific key layout by default
+type keyLayouter interface {
+	keyLayout() KeyLayout
+}
+
 // Event is a Fridge event
 type Event struct {
 	Key  string
 	Type string
 }
 
+// OversizedValueError is returned when a value exceeds the maximum value size
+type OversizedValueError struct {
+	Key     string
+	Size    int
+	MaxSize int
+}
+
+// Error returns the error message
+func (e *OversizedValueError) Error() string {
+	return fmt.Sprintf(oversizedErrorFormat, e.Key, e.Size, e.MaxSize)
+}
+
 // Client fridge client
 type Client struct {
-	defaults    *Defaults
-	dao         *Dao
-	bus         *eventbus.Client
-	group       *parallelizer.Group
-	handleEvent func(event *Event)
+	defaults       *Defaults
+	dao            *Dao
+	bus            *eventbus.Client
+	group          *parallelizer.Group
+	handleEvent    func(event *Event)
+	schedules      map[string]*schedule
+	schedulesMutex sync.Mutex
+	schedulesGroup sync.WaitGroup
+	loaders        []*registeredLoader
+	loadersMutex   sync.RWMutex
+	restocksGroup  sync.WaitGroup
 }
 
 // Put an item
 func (c *Client) Put(key string, value string, options ...StorageOption) error {
-	storageDetails := newStorageDetails(c.defaults, options...)
-	if storageDetails.BestBy > storageDetails.UseBy {
-		return errors.New(invalidDurationsError)
-	}
-
-	err := c.dao.SetStorageDetails(key, storageDetails)
-	if err != nil {
-		return err
-	}
+	_, err := c.put(key, value, options...)
+	return err
+}
 
-	err = c.dao.Set(key, value, storageDetails.UseBy)
+// Get an item
+func (c *Client) Get(key string, options ...RetrievalOption) (string, bool, error) {
+	result, err := c.GetDetailed(key, options...)
 	if err != nil {
-		return err
+		return empty, false, err
 	}
-	return nil
+	return result.Value, result.Found, nil
 }
 
-// Get an item
-func (c *Client) Get(key string, options ...RetrievalOption) (string, bool, error) {
+// GetDetailed gets an item like Get, and returns its freshness state, storage details and whether it was restocked
+func (c *Client) GetDetailed(key string, options ...RetrievalOption) (*Result, error) {
 	retrievalDetails := newRetrievalDetails(options...)
-	restock := retrievalDetails.Restock
+	restock := retrievalDetails.ConditionalRestock
+	if restock == nil {
+		restock = unconditionalRestock(retrievalDetails.Restock)
+	}
+
+	if restock == nil {
+		restock = c.findRestock(key)
+	}
 
 	storageDetails, found, err := c.dao.GetStorageDetails(key)
 	if err != nil {
-		return empty, false, err
+		corruptMetadataError, ok := err.(*CorruptMetadataError)
+		if !ok {
+			return nil, err
+		}
+		return c.recover(key, corruptMetadataError, restock)
 	}
 
 	if !found {
 		c.publish(key, NotFound)
-		return empty, false, err
+		return &Result{State: NotFound}, nil
 	}
 
-	cachedValue, found, err := c.dao.Get(key)
+	cachedValue, found, err := c.dao.Load(key, storageDetails)
 	if err != nil {
-		return empty, false, err
+		return nil, err
 	}
 
 	if !found {
+		storageDetails.Validator = empty
 		c.publish(key, Expired)
-		return c.restock(key, cachedValue, storageDetails, restock)
+		return c.restockResult(key, Expired, cachedValue, false, storageDetails, restock)
 	}
 
 	now := time.Now().UTC()
-	if now.Before(storageDetails.Timestamp.Add(storageDetails.BestBy)) {
-		c.publish(key, Fresh)
-		return cachedValue, true, nil
+	state := freshness(storageDetails, now)
+	c.publish(key, state)
+
+	switch state {
+	case Fresh:
+		result := newResult(Fresh, cachedValue, storageDetails, now)
+		if restock != nil && c.shouldRefreshEarly(storageDetails, now) {
+			c.publish(key, EarlyRefresh)
+			result.RestockScheduled = c.restockInBackground(key, cachedValue, storageDetails, restock)
+		}
+		return result, nil
+	case Cold:
+		result := newResult(Cold, cachedValue, storageDetails, now)
+		result.RestockScheduled = c.restockInBackground(key, cachedValue, storageDetails, restock)
+		return result, nil
 	}
+	return c.restockResult(key, Expired, cachedValue, true, storageDetails, restock)
+}
 
-	if now.Before(storageDetails.Timestamp.Add(storageDetails.UseBy)) {
-		c.publish(key, Cold)
-		if !storageDetails.Restocking {
-			go c.group.Add(func() {
-				c.restock(key, cachedValue, storageDetails, restock)
-			})
-		}
-		return cachedValue, true, nil
+// Touch restarts an item's "Best By" and "Use By" durations, optionally changed by the options, and extends
+// the expiration of its value without rewriting it. It returns whether the item was found
+func (c *Client) Touch(key string, options ...StorageOption) (bool, error) {
+	storageDetails, found, err := c.dao.GetStorageDetails(key)
+	if err != nil || !found {
+		return false, err
 	}
+	return c.touch(key, storageDetails, options...)
+}
 
-	c.publish(key, Expired)
-	return c.restock(key, cachedValue, storageDetails, restock)
+// Invalidate marks an item as past its "Best By" or "Use By" duration while keeping its value, so that the next read
+// restocks it like a cold or expired item. It returns whether the item was found
+func (c *Client) Invalidate(key string, invalidation Invalidation) (bool, error) {
+	return c.dao.Invalidate(key, invalidation)
+}
+
+// InvalidateTag invalidates every item that was put with a tag, and returns how many items were invalidated
+func (c *Client) InvalidateTag(tag string, invalidation Invalidation) (int, error) {
+	return c.dao.InvalidateTag(tag, invalidation)
 }
 
 // Remove an item
@@ -163,6 +289,11 @@ func (c *Client) Remove(key string) error {
 	return c.dao.Remove(key)
 }
 
+// Sweep removes orphaned storage details in batches and returns how many were removed
+func (c *Client) Sweep(batchSize int) (int, error) {
+	return c.dao.Sweep(batchSize)
+}
+
 // Ping pings redis
 func (c *Client) Ping() error {
 	return c.dao.Ping()
@@ -170,6 +301,8 @@ func (c *Client) Ping() error {
 
 // Close closes resources
 func (c *Client) Close() error {
+	c.closeSchedules()
+	c.restocksGroup.Wait()
 	c.bus.Close()
 	c.group.Close()
 	return c.dao.Close()
@@ -184,35 +317,203 @@ func (c *Client) publish(key string, eventType string) {
 	c.bus.Publish(eventsTopic, &Event{Key: key, Type: eventType})
 }
 
-func (c *Client) restock(key string, cachedValue string, storageDetails *StorageDetails, callback func() (string, error)) (string, bool, error) {
+func (c *Client) recover(key string, corruptMetadataError *CorruptMetadataError, callback ConditionalRestock) (*Result, error) {
+	c.publish(key, CorruptMetadata)
+
+	switch c.defaults.CorruptMetadataPolicy {
+	case IgnoreCorruptMetadata:
+	case RemoveCorruptMetadata:
+		err := c.dao.Remove(key)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, corruptMetadataError
+	}
+
+	c.publish(key, NotFound)
+	return c.restockResult(key, CorruptMetadata, empty, false, c.newStorageDetails(key), callback)
+}
+
+// freshness returns whether an item is Fresh, Cold or Expired
+func freshness(storageDetails *StorageDetails, now time.Time) string {
+	switch {
+	case storageDetails.Invalidation >= InvalidateUseBy:
+		return Expired
+	case storageDetails.Invalidation == InvalidateBestBy:
+		if now.Before(storageDetails.Timestamp.Add(storageDetails.UseBy)) {
+			return Cold
+		}
+		return Expired
+	case now.Before(storageDetails.Timestamp.Add(storageDetails.BestBy)):
+		return Fresh
+	case now.Before(storageDetails.Timestamp.Add(storageDetails.UseBy)):
+		return Cold
+	}
+	return Expired
+}
+
+// newStorageDetails returns storage details with the durations of the key's policy, unless overridden by the options
+func (c *Client) newStorageDetails(key string, options ...StorageOption) *StorageDetails {
+	policy := c.defaults.policy(key)
+	options = append([]StorageOption{WithDurations(policy.BestBy, policy.UseBy)}, options...)
+	return newStorageDetails(c.defaults, options...)
+}
+
+// shouldRefreshEarly implements XFetch, where a fresh item is restocked once now - restockDuration * beta * ln(random)
+// passes its "Best By" duration, so that reads restock an item early with a rising chance as it approaches it
+func (c *Client) shouldRefreshEarly(storageDetails *StorageDetails, now time.Time) bool {
+	beta := c.defaults.EarlyRefreshBeta
+	if beta <= 0 || storageDetails.RestockDuration <= 0 || storageDetails.Restocking {
+		return false
+	}
+
+	gap := -float64(storageDetails.RestockDuration) * beta * math.Log(1-rand.Float64())
+	return !now.Add(time.Duration(gap)).Before(storageDetails.Timestamp.Add(storageDetails.BestBy))
+}
+
+// restockInBackground restocks an item unless it is already being restocked, and returns whether a restock function was scheduled
+func (c *Client) restockInBackground(key string, cachedValue string, storageDetails *StorageDetails, callback ConditionalRestock) bool {
+	if storageDetails.Restocking {
+		return false
+	}
+
+	c.restocksGroup.Add(1)
+	go c.group.Add(func() {
+		defer c.restocksGroup.Done()
+		c.restock(key, cachedValue, true, storageDetails, callback)
+	})
+	return callback != nil
+}
+
+func (c *Client) restockResult(key string, state string, cachedValue string, cached bool, storageDetails *StorageDetails, callback ConditionalRestock) (*Result, error) {
+	freshValue, freshStorageDetails, found, err := c.restock(key, cachedValue, cached, storageDetails, callback)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Value: freshValue, Found: found, State: state, Restocked: found}
+	if freshStorageDetails != nil {
+		result = newResult(state, freshValue, freshStorageDetails, time.Now().UTC())
+		result.Restocked = true
+	}
+	return result, nil
+}
+
+// put stores an item and returns its storage details, or nil when an oversized value was skipped
+func (c *Client) put(key string, value string, options ...StorageOption) (*StorageDetails, error) {
+	storageDetails := c.newStorageDetails(key, options...)
+	if storageDetails.BestBy < 0 || storageDetails.BestBy > storageDetails.UseBy {
+		return nil, errors.New(invalidDurationsError)
+	}
+
+	err := c.dao.checkTags(storageDetails)
+	if err != nil {
+		return nil, err
+	}
+
+	maxValueSize := c.defaults.MaxValueSize
+	if maxValueSize > 0 && len(value) > maxValueSize {
+		c.publish(key, Oversized)
+		switch c.defaults.OversizedValuePolicy {
+		case SkipOversizedValues:
+			return nil, nil
+		case ChunkOversizedValues:
+		default:
+			return nil, &OversizedValueError{Key: key, Size: len(value), MaxSize: maxValueSize}
+		}
+	}
+
+	err = c.dao.Store(key, value, storageDetails)
+	if err != nil {
+		return nil, err
+	}
+	return storageDetails, nil
+}
+
+// touch restarts an item's durations with its storage details changed by the options, clearing any restock or invalidation
+func (c *Client) touch(key string, storageDetails *StorageDetails, options ...StorageOption) (bool, error) {
+	for _, option := range options {
+		option(storageDetails)
+	}
+
+	if storageDetails.BestBy < 0 || storageDetails.BestBy > storageDetails.UseBy {
+		return false, errors.New(invalidDurationsError)
+	}
+
+	err := c.dao.checkTags(storageDetails)
+	if err != nil {
+		return false, err
+	}
+
+	storageDetails.Restocking = false
+	storageDetails.Invalidation = 0
+	return c.dao.Touch(key, storageDetails)
+}
+
+// restock restocks an item and returns the fresh value and the storage details it was stored with, if it was stored.
+// An item without a cached value cannot be left unmodified, so the restock function reporting so is an error
+func (c *Client) restock(key string, cachedValue string, cached bool, storageDetails *StorageDetails, callback ConditionalRestock) (string, *StorageDetails, bool, error) {
 	if callback == nil {
 		c.publish(key, OutOfStock)
-		return empty, false, nil
+		return empty, nil, false, nil
 	}
 
 	storageDetails.Restocking = true
 	err := c.dao.SetStorageDetails(key, storageDetails)
 	if err != nil {
-		return empty, false, err
+		return empty, nil, false, err
+	}
+
+	start := time.Now()
+	freshValue, validator, modified, err := callback(cachedValue, storageDetails.Validator)
+	restockDuration := time.Since(start)
+	if err == nil && !modified && !cached {
+		err = errors.New(notModifiedError)
 	}
 
-	freshValue, err := callback()
 	if err != nil {
 		storageDetails.Restocking = false
 		c.dao.SetStorageDetails(key, storageDetails)
-		return empty, false, err
+		return empty, nil, false, err
 	}
 
 	c.publish(key, Restock)
 
 	bestBy, useBy := storageDetails.BestBy, storageDetails.UseBy
-	err = c.Put(key, freshValue, WithDurations(bestBy, useBy))
+	policy := c.defaults.policy(key)
+	if len(policy.Pattern) > 0 {
+		bestBy, useBy = policy.BestBy, policy.UseBy
+	}
+
+	if !modified {
+		freshValue = cachedValue
+	}
+
+	if freshValue == cachedValue {
+		_, ok := c.dao.cache.(Expirer)
+		if ok {
+			touched, err := c.touch(key, storageDetails, WithDurations(bestBy, useBy), withRestockDuration(restockDuration), WithValidator(validator))
+			if err == nil && touched {
+				c.publish(key, Unchanged)
+				return freshValue, storageDetails, true, nil
+			}
+		}
+	}
+
+	options := []StorageOption{WithDurations(bestBy, useBy), WithTags(storageDetails.Tags...), withRestockDuration(restockDuration), WithValidator(validator)}
+	freshStorageDetails, err := c.put(key, freshValue, options...)
+	if err != nil || freshStorageDetails == nil {
+		storageDetails.Restocking = false
+		c.dao.SetStorageDetails(key, storageDetails)
+	}
+
 	if err != nil {
-		return empty, false, err
+		return empty, nil, false, err
 	}
 
 	if freshValue == cachedValue {
 		c.publish(key, Unchanged)
 	}
-	return freshValue, true, nil
+	return freshValue, freshStorageDetails, true, nil
 }
@@ -0,0 +1,446 @@
+package fridge
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestClient_CorruptMetadata(t *testing.T) {
+	restock := func() (string, error) {
+		return "Hot Pizza", nil
+	}
+
+	cache := newMemoryCache()
+	client := NewClient(cache)
+	defer client.Close()
+
+	cache.memory["food.config"] = "Pizza"
+
+	_, found, err := client.Get("food", WithRestock(restock))
+	assert.False(t, found)
+	assert.IsType(t, &CorruptMetadataError{}, err)
+
+	cache = newMemoryCache()
+	client = NewClient(cache, WithCorruptMetadataPolicy(RemoveCorruptMetadata))
+	defer client.Close()
+
+	cache.memory["food"] = "Pizza"
+	cache.memory["food.config"] = "Pizza"
+
+	value, found, err := client.Get("food", WithRestock(restock))
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, value, "Hot Pizza")
+}
+
+func TestClient_OversizedValue(t *testing.T) {
+	restock := func() (string, error) {
+		return "Hot Pizza", nil
+	}
+
+	cache := newMemoryCache()
+	client := NewClient(cache, WithMaxValueSize(5, RejectOversizedValues))
+	defer client.Close()
+
+	err := client.Put("food", "Hot Pizza")
+	assert.IsType(t, &OversizedValueError{}, err)
+	assert.NotContains(t, cache.memory, "food")
+
+	_, found, err := client.Get("food", WithRestock(restock))
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	cache = newMemoryCache()
+	client = NewClient(cache, WithMaxValueSize(5, SkipOversizedValues))
+	defer client.Close()
+
+	assert.Nil(t, client.Put("food", "Hot Pizza"))
+	assert.NotContains(t, cache.memory, "food")
+	assert.Nil(t, client.Put("food", "Pizza"))
+	assert.Equal(t, cache.memory["food"], "Pizza")
+
+	cache.memory["food"] = "Cold"
+	cache.memory["food.config"] = `{"Version":1,"Timestamp":"2000-01-01T00:00:00Z","BestBy":1,"UseBy":2}`
+
+	value, found, err := client.Get("food", WithRestock(restock))
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, value, "Hot Pizza")
+	assert.Equal(t, cache.memory["food"], "Cold")
+}
+
+func TestClient_ChunkOversizedValues(t *testing.T) {
+	restock := func() (string, error) {
+		return "Hot Pizza", nil
+	}
+
+	cache := newMemoryCache()
+	client := NewClient(cache, WithMaxValueSize(4, ChunkOversizedValues))
+	defer client.Close()
+
+	assert.Nil(t, client.Put("food", "Cold Pizza"))
+	assert.Equal(t, cache.memory["food:chunk:2"], "za")
+
+	value, found, err := client.Get("food", WithRestock(restock))
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, value, "Cold Pizza")
+
+	delete(cache.memory, "food:chunk:1")
+
+	value, found, err = client.Get("food", WithRestock(restock))
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, value, "Hot Pizza")
+	assert.Equal(t, cache.memory["food:chunk:1"], "Pizz")
+}
+
+func TestClient_ShouldRefreshEarly(t *testing.T) {
+	now := time.Now().UTC()
+	storageDetails := &StorageDetails{Timestamp: now, BestBy: time.Hour, RestockDuration: time.Second}
+
+	client := NewClient(newMemoryCache())
+	defer client.Close()
+
+	assert.False(t, client.shouldRefreshEarly(storageDetails, now))
+
+	client = NewClient(newMemoryCache(), WithEarlyRefresh(1e-6))
+	defer client.Close()
+
+	assert.False(t, client.shouldRefreshEarly(storageDetails, now))
+	assert.True(t, client.shouldRefreshEarly(storageDetails, now.Add(time.Hour)))
+
+	client = NewClient(newMemoryCache(), WithEarlyRefresh(1e9))
+	defer client.Close()
+
+	assert.True(t, client.shouldRefreshEarly(storageDetails, now))
+	assert.False(t, client.shouldRefreshEarly(&StorageDetails{Timestamp: now, BestBy: time.Hour}, now))
+}
+
+func TestClient_EarlyRefresh(t *testing.T) {
+	refreshed := make(chan bool, 1)
+	restock := func() (string, error) {
+		time.Sleep(time.Millisecond)
+		refreshed <- true
+		return "Hot Pizza", nil
+	}
+
+	cache := newMemoryCache()
+	client := NewClient(cache, WithEarlyRefresh(1e9))
+	defer client.Close()
+
+	cache.memory["food.config"] = `{"Version":1}`
+
+	value, found, err := client.Get("food", WithRestock(restock))
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, value, "Hot Pizza")
+	<-refreshed
+
+	storageDetails, _, _ := client.dao.GetStorageDetails("food")
+	assert.True(t, storageDetails.RestockDuration >= time.Millisecond)
+
+	value, found, err = client.Get("food", WithRestock(restock))
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, value, "Hot Pizza")
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		assert.Fail(t, "item was not refreshed early")
+	}
+}
+
+func TestClient_EarlyRefreshWithoutRestock(t *testing.T) {
+	cache := newMemoryCache()
+	client := NewClient(cache, WithEarlyRefresh(1e9))
+	defer client.Close()
+
+	storageDetails := &StorageDetails{BestBy: time.Hour, UseBy: time.Hour, RestockDuration: time.Millisecond}
+	assert.Nil(t, client.dao.Store("food", "Pizza", storageDetails))
+
+	events := make(chan string, 10)
+	client.HandleEvent(func(event *Event) {
+		events <- event.Type
+	})
+
+	result, err := client.GetDetailed("food")
+	assert.Nil(t, err)
+	assert.Equal(t, result.State, Fresh)
+	assert.False(t, result.RestockScheduled)
+
+	assert.Equal(t, <-events, Fresh)
+
+	select {
+	case eventType := <-events:
+		assert.Fail(t, "unexpected event", eventType)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestClient_Freshness(t *testing.T) {
+	now := time.Now().UTC()
+	storageDetails := &StorageDetails{Timestamp: now, BestBy: time.Minute, UseBy: time.Hour}
+
+	assert.Equal(t, freshness(storageDetails, now), Fresh)
+	assert.Equal(t, freshness(storageDetails, now.Add(time.Minute)), Cold)
+	assert.Equal(t, freshness(storageDetails, now.Add(time.Hour)), Expired)
+
+	storageDetails.Invalidation = InvalidateBestBy
+	assert.Equal(t, freshness(storageDetails, now), Cold)
+	assert.Equal(t, freshness(storageDetails, now.Add(time.Hour)), Expired)
+
+	storageDetails.Invalidation = InvalidateUseBy
+	assert.Equal(t, freshness(storageDetails, now), Expired)
+}
+
+func TestClient_Invalidate(t *testing.T) {
+	restocked := make(chan bool, 1)
+	restock := func() (string, error) {
+		restocked <- true
+		return "Hot Pizza", nil
+	}
+
+	cache := newMemoryCache()
+	client := NewClient(cache)
+	defer client.Close()
+
+	found, err := client.Invalidate("food", InvalidateBestBy)
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	assert.Nil(t, client.Put("food", "Pizza"))
+	storageDetails, _, _ := client.dao.GetStorageDetails("food")
+
+	found, err = client.Invalidate("food", InvalidateBestBy)
+	assert.Nil(t, err)
+	assert.True(t, found)
+
+	invalidatedStorageDetails, _, _ := client.dao.GetStorageDetails("food")
+	assert.Equal(t, invalidatedStorageDetails.Timestamp, storageDetails.Timestamp)
+	assert.Equal(t, cache.memory["food"], "Pizza")
+
+	result, err := client.GetDetailed("food", WithRestock(restock))
+	assert.Nil(t, err)
+	assert.Equal(t, result.State, Cold)
+	assert.Equal(t, result.Value, "Pizza")
+	<-restocked
+
+	assert.True(t, eventually(func() bool {
+		result, _ := client.Peek("food")
+		return result.State == Fresh && result.Value == "Hot Pizza"
+	}))
+
+	found, err = client.Invalidate("food", InvalidateUseBy)
+	assert.Nil(t, err)
+	assert.True(t, found)
+
+	found, err = client.Invalidate("food", InvalidateBestBy)
+	assert.Nil(t, err)
+	assert.True(t, found)
+
+	result, err = client.GetDetailed("food", WithRestock(restock))
+	assert.Nil(t, err)
+	assert.Equal(t, result.State, Expired)
+	assert.Equal(t, result.Value, "Hot Pizza")
+	assert.True(t, result.Restocked)
+	<-restocked
+
+	result, err = client.GetDetailed("food")
+	assert.Nil(t, err)
+	assert.Equal(t, result.State, Fresh)
+}
+
+// plainCache hides the optional interfaces of a cache
+type plainCache struct {
+	Cache
+}
+
+func TestClient_TagsNotSupported(t *testing.T) {
+	cache := newMemoryCache()
+	client := NewClient(&plainCache{Cache: cache})
+	defer client.Close()
+
+	err := client.Put("food", "Pizza", WithTags("customer"))
+	assert.Equal(t, err.Error(), tagsNotSupportedError)
+	assert.Empty(t, cache.memory)
+
+	_, err = client.InvalidateTag("customer", InvalidateUseBy)
+	assert.Equal(t, err.Error(), tagsNotSupportedError)
+}
+
+func TestClient_InvalidateTag(t *testing.T) {
+	restock := func() (string, error) {
+		return "Hot Pizza", nil
+	}
+
+	cache := newMemoryCache()
+	client := NewClient(cache)
+	defer client.Close()
+
+	assert.Nil(t, client.Put("food", "Pizza", WithTags("customer")))
+
+	invalidated, err := client.InvalidateTag("customer", InvalidateUseBy)
+	assert.Nil(t, err)
+	assert.Equal(t, invalidated, 1)
+
+	result, err := client.GetDetailed("food", WithRestock(restock))
+	assert.Nil(t, err)
+	assert.Equal(t, result.State, Expired)
+	assert.Equal(t, result.StorageDetails.Tags, []string{"customer"})
+
+	invalidated, err = client.InvalidateTag("customer", InvalidateUseBy)
+	assert.Nil(t, err)
+	assert.Equal(t, invalidated, 1)
+}
+
+func TestClient_Touch(t *testing.T) {
+	cache := newMemoryCache()
+	client := NewClient(cache)
+	defer client.Close()
+
+	found, err := client.Touch("food")
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	assert.Nil(t, client.Put("food", "Pizza", WithDurations(time.Minute, time.Hour), WithTags("customer")))
+	storageDetails, _, _ := client.dao.GetStorageDetails("food")
+
+	_, err = client.Invalidate("food", InvalidateUseBy)
+	assert.Nil(t, err)
+
+	found, err = client.Touch("food", WithDurations(time.Hour, 2*time.Hour))
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, cache.memory["food"], "Pizza")
+	assert.Equal(t, cache.timeouts["food"], 2*time.Hour)
+
+	touchedStorageDetails, _, _ := client.dao.GetStorageDetails("food")
+	assert.True(t, touchedStorageDetails.Timestamp.After(storageDetails.Timestamp))
+	assert.Equal(t, touchedStorageDetails.BestBy, time.Hour)
+	assert.Equal(t, touchedStorageDetails.UseBy, 2*time.Hour)
+	assert.Equal(t, touchedStorageDetails.Invalidation, Invalidation(0))
+	assert.Equal(t, touchedStorageDetails.Tags, []string{"customer"})
+
+	_, err = client.Touch("food", WithDurations(time.Hour, time.Minute))
+	assert.NotNil(t, err)
+
+	assert.Nil(t, cache.Remove("food"))
+
+	found, err = client.Touch("food")
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
+
+type countingCache struct {
+	*memoryCache
+	sets map[string]int
+}
+
+func (c *countingCache) Set(key string, value string, timeout time.Duration) error {
+	c.sets[key]++
+	return c.memoryCache.Set(key, value, timeout)
+}
+
+func TestClient_UnchangedRestock(t *testing.T) {
+	restock := func() (string, error) {
+		return "Pizza", nil
+	}
+
+	cache := &countingCache{memoryCache: newMemoryCache(), sets: make(map[string]int)}
+	client := NewClient(cache)
+	defer client.Close()
+
+	events := make(chan string, 10)
+	client.HandleEvent(func(event *Event) {
+		events <- event.Type
+	})
+
+	assert.Nil(t, client.Put("food", "Pizza"))
+	_, err := client.Invalidate("food", InvalidateUseBy)
+	assert.Nil(t, err)
+
+	result, err := client.GetDetailed("food", WithRestock(restock))
+	assert.Nil(t, err)
+	assert.Equal(t, result.State, Expired)
+	assert.Equal(t, result.Value, "Pizza")
+	assert.True(t, result.Restocked)
+	assert.Equal(t, cache.sets["food"], 1)
+	assert.Equal(t, <-events, Expired)
+	assert.Equal(t, <-events, Restock)
+	assert.Equal(t, <-events, Unchanged)
+
+	result, err = client.GetDetailed("food")
+	assert.Nil(t, err)
+	assert.Equal(t, result.State, Fresh)
+	assert.False(t, result.StorageDetails.Restocking)
+}
+
+func TestClient_ConditionalRestock(t *testing.T) {
+	validators := make(chan string, 1)
+	restock := func(cachedValue string, validator string) (string, string, bool, error) {
+		validators <- validator
+		if validator == `"v1"` {
+			return empty, `"v2"`, false, nil
+		}
+		return "Hot Pizza", `"v3"`, true, nil
+	}
+
+	cache := &countingCache{memoryCache: newMemoryCache(), sets: make(map[string]int)}
+	client := NewClient(cache)
+	defer client.Close()
+
+	assert.Nil(t, client.Put("food", "Pizza", WithValidator(`"v1"`)))
+	_, err := client.Invalidate("food", InvalidateUseBy)
+	assert.Nil(t, err)
+
+	result, err := client.GetDetailed("food", WithConditionalRestock(restock))
+	assert.Nil(t, err)
+	assert.Equal(t, <-validators, `"v1"`)
+	assert.Equal(t, result.Value, "Pizza")
+	assert.True(t, result.Restocked)
+	assert.Equal(t, result.StorageDetails.Validator, `"v2"`)
+	assert.Equal(t, cache.sets["food"], 1)
+
+	_, err = client.Invalidate("food", InvalidateUseBy)
+	assert.Nil(t, err)
+
+	result, err = client.GetDetailed("food", WithConditionalRestock(restock))
+	assert.Nil(t, err)
+	assert.Equal(t, <-validators, `"v2"`)
+	assert.Equal(t, result.Value, "Hot Pizza")
+	assert.Equal(t, result.StorageDetails.Validator, `"v3"`)
+	assert.Equal(t, cache.sets["food"], 2)
+
+	assert.Nil(t, cache.Remove("food"))
+
+	result, err = client.GetDetailed("food", WithConditionalRestock(restock))
+	assert.Nil(t, err)
+	assert.Equal(t, <-validators, empty)
+	assert.Equal(t, result.Value, "Hot Pizza")
+}
+
+func TestClient_ConditionalRestockNotCached(t *testing.T) {
+	restock := func(cachedValue string, validator string) (string, string, bool, error) {
+		return empty, `"v1"`, false, nil
+	}
+
+	cache := newMemoryCache()
+	client := NewClient(cache)
+	defer client.Close()
+
+	assert.Nil(t, client.Put("food", "Pizza", WithValidator(`"v1"`)))
+	assert.Nil(t, cache.Remove("food"))
+
+	_, err := client.GetDetailed("food", WithConditionalRestock(restock))
+	assert.NotNil(t, err)
+	assert.Equal(t, err.Error(), notModifiedError)
+	assert.NotContains(t, cache.memory, "food")
+
+	storageDetails, found, err := client.dao.GetStorageDetails("food")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.False(t, storageDetails.Restocking)
+}
@@ -0,0 +1,96 @@
+package fridge
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	generationKey = "generation:current"
+
+	countersNotSupportedError = "cache does not support counters"
+)
+
+type generation struct {
+	value    int64
+	loadedAt time.Time
+}
+
+// generations caches the generation counters of namespaces for a refresh interval
+type generations struct {
+	mutex           sync.Mutex
+	cache           Cache
+	refreshInterval time.Duration
+	values          map[string]*generation
+}
+
+// BumpGeneration increments the generation of a namespace, which is part of the keys of clients configured
+// WithGenerations, so that every item stored in the namespace is invalidated at once and left to expire
+func (c *Client) BumpGeneration(namespace string) (int64, error) {
+	counter, ok := c.dao.cache.(Counter)
+	if !ok {
+		return 0, errors.New(countersNotSupportedError)
+	}
+
+	value, err := counter.Increment(generationKeyOf(namespace))
+	if err != nil {
+		return 0, err
+	}
+
+	if c.dao.generations != nil {
+		c.dao.generations.set(namespace, value)
+	}
+	return value, nil
+}
+
+// current returns the generation of a namespace, reloading it once the refresh interval has passed.
+// The last loaded generation is kept when it cannot be reloaded, and an error is returned when none was loaded yet
+func (g *generations) current(namespace string) (int64, error) {
+	g.mutex.Lock()
+	cached, ok := g.values[namespace]
+	g.mutex.Unlock()
+
+	now := time.Now()
+	if ok && now.Sub(cached.loadedAt) < g.refreshInterval {
+		return cached.value, nil
+	}
+
+	value, err := g.load(namespace)
+	if err != nil {
+		if ok {
+			return cached.value, nil
+		}
+		return 0, err
+	}
+
+	g.set(namespace, value)
+	return value, nil
+}
+
+func (g *generations) set(namespace string, value int64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.values[namespace] = &generation{value: value, loadedAt: time.Now()}
+}
+
+func (g *generations) load(namespace string) (int64, error) {
+	value, found, err := g.cache.Get(generationKeyOf(namespace))
+	if err != nil || !found {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func generationKeyOf(namespace string) string {
+	if len(namespace) == 0 {
+		return generationKey
+	}
+	return namespace + namespaceSeparator + generationKey
+}
+
+func newGenerations(cache Cache, refreshInterval time.Duration) *generations {
+	return &generations{cache: cache, refreshInterval: refreshInterval, values: make(map[string]*generation)}
+}
@@ -0,0 +1,138 @@
+package fridge
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestClient_BumpGeneration(t *testing.T) {
+	cache := newMemoryCache()
+	client := NewClient(cache, WithNamespace("service"), WithGenerations(time.Hour))
+	defer client.Close()
+
+	assert.Nil(t, client.Put("food", "Pizza"))
+	assert.Contains(t, cache.memory, "service:0:food")
+
+	generation, err := client.BumpGeneration("service")
+	assert.Nil(t, err)
+	assert.Equal(t, generation, int64(1))
+	assert.Equal(t, cache.memory["service:generation:current"], "1")
+
+	result, err := client.Peek("food")
+	assert.Nil(t, err)
+	assert.Equal(t, result.State, NotFound)
+
+	assert.Nil(t, client.Put("food", "Pasta"))
+	assert.Contains(t, cache.memory, "service:1:food")
+	assert.Equal(t, cache.memory["service:0:food"], "Pizza")
+
+	other := NewClient(cache, WithNamespace("service"), WithGenerations(0))
+	defer other.Close()
+
+	cachedValue, found, err := other.Get("food")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, cachedValue, "Pasta")
+}
+
+func TestClient_GenerationKeys(t *testing.T) {
+	cache := newMemoryCache()
+	client := NewClient(cache, WithKeyLayout(NewLegacyLayout()), WithGenerations(0))
+	defer client.Close()
+
+	assert.Nil(t, client.Put(generationKey, "Pizza"))
+	assert.Equal(t, cache.memory["0:"+generationKey], "Pizza")
+	assert.NotContains(t, cache.memory, generationKey)
+
+	generation, err := client.BumpGeneration(empty)
+	assert.Nil(t, err)
+	assert.Equal(t, generation, int64(1))
+}
+
+// bumpingCache bumps the generation after every value it sets
+type bumpingCache struct {
+	*memoryCache
+}
+
+func (c *bumpingCache) Set(key string, value string, timeout time.Duration) error {
+	err := c.memoryCache.Set(key, value, timeout)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.memoryCache.Increment(generationKey)
+	return err
+}
+
+func TestDao_GenerationPerOperation(t *testing.T) {
+	cache := &bumpingCache{memoryCache: newMemoryCache()}
+	dao := newDao(cache, newDefaults(WithGenerations(0)))
+
+	assert.Nil(t, dao.Store("food", "Pizza", &StorageDetails{UseBy: time.Hour}))
+	assert.Contains(t, cache.memory, "0:food")
+	assert.Contains(t, cache.memory, "0:food.config")
+	assert.Equal(t, cache.memory[generationKey], "2")
+}
+
+func TestGenerations_Current(t *testing.T) {
+	cache := newMemoryCache()
+	generations := newGenerations(cache, time.Hour)
+
+	generation, err := generations.current(empty)
+	assert.Nil(t, err)
+	assert.Equal(t, generation, int64(0))
+
+	cache.Increment(generationKey)
+	generation, err = generations.current(empty)
+	assert.Nil(t, err)
+	assert.Equal(t, generation, int64(0))
+
+	generations = newGenerations(cache, 0)
+	generation, err = generations.current(empty)
+	assert.Nil(t, err)
+	assert.Equal(t, generation, int64(1))
+
+	cache.memory[generationKey] = "Pizza"
+	generation, err = generations.current(empty)
+	assert.Nil(t, err)
+	assert.Equal(t, generation, int64(1))
+
+	generations = newGenerations(cache, 0)
+	_, err = generations.current(empty)
+	assert.NotNil(t, err)
+}
+
+// failingCounterCache fails to read the generation counter
+type failingCounterCache struct {
+	*memoryCache
+}
+
+func (c *failingCounterCache) Get(key string) (string, bool, error) {
+	if key == generationKey {
+		return empty, false, errors.New("connection refused")
+	}
+	return c.memoryCache.Get(key)
+}
+
+func TestClient_GenerationUnavailable(t *testing.T) {
+	cache := newMemoryCache()
+	client := NewClient(cache, WithGenerations(0))
+	defer client.Close()
+
+	assert.Nil(t, client.Put("food", "Old"))
+
+	_, err := client.BumpGeneration(empty)
+	assert.Nil(t, err)
+
+	other := NewClient(&failingCounterCache{memoryCache: cache}, WithGenerations(0))
+	defer other.Close()
+
+	_, found, err := other.Get("food")
+	assert.NotNil(t, err)
+	assert.False(t, found)
+
+	assert.NotNil(t, other.Put("food", "New"))
+	assert.Equal(t, cache.memory["0:food"], "Old")
+}
@@ -1,10 +1,14 @@
 module github.com/shomali11/fridge
 
+go 1.16
+
 require (
-	github.com/FZambia/go-sentinel v0.0.0-20171204085413-76bd05e8e22f // indirect
+	github.com/FZambia/go-sentinel v0.0.0-20171204085413-76bd05e8e22f
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/garyburd/redigo v1.6.0 // indirect
+	github.com/garyburd/redigo v1.6.0
+	github.com/golang/snappy v0.0.4
 	github.com/gomodule/redigo v2.0.0+incompatible // indirect
+	github.com/klauspost/compress v1.15.9
 	github.com/rafaeljusto/redigomock v0.0.0-20190202135759-257e089e14a1 // indirect
 	github.com/shomali11/eventbus v0.0.0-20190207034150-f2f444f3a284
 	github.com/shomali11/maps v0.0.0-20180607005330-ed4929916122 // indirect
@@ -0,0 +1,53 @@
+package fridge
+
+import (
+	"time"
+)
+
+// Inspection contains what is stored for an item. The sizes are of the stored bytes, after compression and encryption,
+// and the remaining times to live are only set when the cache implements Inspector
+type Inspection struct {
+	StorageDetails     *StorageDetails
+	StorageDetailsSize int
+	StorageDetailsTTL  time.Duration
+	ValueFound         bool
+	ValueSize          int
+	ValueTTL           time.Duration
+	TTLSupported       bool
+}
+
+// Peek returns an item and its freshness state without restocking it or publishing events
+func (c *Client) Peek(key string) (*Result, error) {
+	storageDetails, found, err := c.dao.GetStorageDetails(key)
+	if err != nil {
+		_, ok := err.(*CorruptMetadataError)
+		if !ok {
+			return nil, err
+		}
+		return &Result{State: CorruptMetadata}, nil
+	}
+
+	if !found {
+		return &Result{State: NotFound}, nil
+	}
+
+	cachedValue, found, err := c.dao.Load(key, storageDetails)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if !found {
+		result := newResult(Expired, empty, storageDetails, now)
+		result.Found = false
+		return result, nil
+	}
+
+	return newResult(freshness(storageDetails, now), cachedValue, storageDetails, now), nil
+}
+
+// Inspect returns an item's storage details and the sizes and remaining time to live of what is stored for it,
+// including items whose value has expired but whose storage details remain
+func (c *Client) Inspect(key string) (*Inspection, bool, error) {
+	return c.dao.Inspect(key)
+}
@@ -0,0 +1,85 @@
+package fridge
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestClient_Peek(t *testing.T) {
+	cache := newMemoryCache()
+	client := NewClient(cache)
+	defer client.Close()
+
+	events := 0
+	client.HandleEvent(func(event *Event) {
+		events++
+	})
+
+	result, err := client.Peek("food")
+	assert.Nil(t, err)
+	assert.Equal(t, result.State, NotFound)
+
+	assert.Nil(t, client.Put("food", "Pizza"))
+
+	result, err = client.Peek("food")
+	assert.Nil(t, err)
+	assert.True(t, result.Found)
+	assert.Equal(t, result.Value, "Pizza")
+	assert.Equal(t, result.State, Fresh)
+
+	cache.memory["food.config"] = `{"Version":1,"Timestamp":"2000-01-01T00:00:00Z","BestBy":1,"UseBy":9000000000000000000}`
+
+	result, err = client.Peek("food")
+	assert.Nil(t, err)
+	assert.Equal(t, result.State, Cold)
+	assert.False(t, result.RestockScheduled)
+
+	delete(cache.memory, "food")
+
+	result, err = client.Peek("food")
+	assert.Nil(t, err)
+	assert.False(t, result.Found)
+	assert.Equal(t, result.State, Expired)
+
+	cache.memory["food.config"] = "Pizza"
+
+	result, err = client.Peek("food")
+	assert.Nil(t, err)
+	assert.Equal(t, result.State, CorruptMetadata)
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, events, 0)
+}
+
+func TestClient_Inspect(t *testing.T) {
+	cache := newMemoryCache()
+	client := NewClient(cache, WithChunking(2))
+	defer client.Close()
+
+	_, found, err := client.Inspect("food")
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	assert.Nil(t, client.Put("food", "Pizza", WithDurations(time.Minute, time.Hour)))
+
+	inspection, found, err := client.Inspect("food")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.True(t, inspection.TTLSupported)
+	assert.Equal(t, inspection.StorageDetails.Chunks, 3)
+	assert.Equal(t, inspection.StorageDetailsSize, len(cache.memory["food.config"]))
+	assert.Equal(t, inspection.StorageDetailsTTL, time.Hour+defaultGracePeriod)
+	assert.True(t, inspection.ValueFound)
+	assert.Equal(t, inspection.ValueSize, 5)
+	assert.Equal(t, inspection.ValueTTL, time.Hour)
+
+	delete(cache.memory, "food:chunk:1")
+
+	inspection, found, err = client.Inspect("food")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, inspection.StorageDetails.UseBy, time.Hour)
+	assert.False(t, inspection.ValueFound)
+	assert.Equal(t, inspection.ValueSize, 0)
+}
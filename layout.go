@@ -0,0 +1,139 @@
+package fridge
+
+import (
+	"strings"
+)
+
+const (
+	storageDetailsSuffix = ".config"
+	storageDetailsPrefix = "config:"
+	hashTagOpen          = "{"
+	hashTagClose         = "}"
+)
+
+var (
+	keyEscaper   = strings.NewReplacer("%", "%25", ".", "%2E", ":", "%3A", "{", "%7B", "}", "%7D")
+	keyUnescaper = strings.NewReplacer("%25", "%", "%2E", ".", "%3A", ":", "%7B", "{", "%7D", "}")
+	globEscaper  = strings.NewReplacer("\\", "\\\\", "*", "\\*", "?", "\\?", "[", "\\[", "]", "\\]")
+)
+
+// KeyLayout decides how an item's key maps to the cache keys of its value and storage details
+type KeyLayout interface {
+	// ValueKey returns the cache key of an item's value
+	ValueKey(key string) string
+
+	// StorageDetailsKey returns the cache key of an item's storage details
+	StorageDetailsKey(key string) string
+
+	// StorageDetailsPattern returns a pattern that matches every storage details key
+	StorageDetailsPattern() string
+
+	// ParseStorageDetailsKey returns the item's key of a storage details key
+	ParseStorageDetailsKey(storageDetailsKey string) (string, bool)
+}
+
+// NewSuffixLayout stores the storage details under the escaped key followed by ".config"
+func NewSuffixLayout() KeyLayout {
+	return &suffixLayout{escape: true}
+}
+
+// NewLegacyLayout stores the storage details under the verbatim key followed by ".config".
+// Keys are not escaped, so a key named "foo.config" collides with the storage details of "foo"
+func NewLegacyLayout() KeyLayout {
+	return &suffixLayout{escape: false}
+}
+
+// NewPrefixLayout stores the storage details under "config:" followed by the escaped key
+func NewPrefixLayout() KeyLayout {
+	return &prefixLayout{}
+}
+
+// NewHashTagLayout wraps the escaped key in a redis hash tag so that the value and
+// the storage details of an item land in the same redis cluster slot
+func NewHashTagLayout() KeyLayout {
+	return &hashTagLayout{}
+}
+
+type suffixLayout struct {
+	escape bool
+}
+
+func (l *suffixLayout) ValueKey(key string) string {
+	if !l.escape {
+		return key
+	}
+	return escapeKey(key)
+}
+
+func (l *suffixLayout) StorageDetailsKey(key string) string {
+	return l.ValueKey(key) + storageDetailsSuffix
+}
+
+func (l *suffixLayout) StorageDetailsPattern() string {
+	return wildcard + storageDetailsSuffix
+}
+
+func (l *suffixLayout) ParseStorageDetailsKey(storageDetailsKey string) (string, bool) {
+	if !strings.HasSuffix(storageDetailsKey, storageDetailsSuffix) {
+		return empty, false
+	}
+
+	key := strings.TrimSuffix(storageDetailsKey, storageDetailsSuffix)
+	if !l.escape {
+		return key, true
+	}
+	return unescapeKey(key), true
+}
+
+type prefixLayout struct {
+}
+
+func (l *prefixLayout) ValueKey(key string) string {
+	return escapeKey(key)
+}
+
+func (l *prefixLayout) StorageDetailsKey(key string) string {
+	return storageDetailsPrefix + escapeKey(key)
+}
+
+func (l *prefixLayout) StorageDetailsPattern() string {
+	return storageDetailsPrefix + wildcard
+}
+
+func (l *prefixLayout) ParseStorageDetailsKey(storageDetailsKey string) (string, bool) {
+	if !strings.HasPrefix(storageDetailsKey, storageDetailsPrefix) {
+		return empty, false
+	}
+	return unescapeKey(strings.TrimPrefix(storageDetailsKey, storageDetailsPrefix)), true
+}
+
+type hashTagLayout struct {
+}
+
+func (l *hashTagLayout) ValueKey(key string) string {
+	return hashTagOpen + escapeKey(key) + hashTagClose
+}
+
+func (l *hashTagLayout) StorageDetailsKey(key string) string {
+	return l.ValueKey(key) + storageDetailsSuffix
+}
+
+func (l *hashTagLayout) StorageDetailsPattern() string {
+	return hashTagOpen + wildcard + hashTagClose + storageDetailsSuffix
+}
+
+func (l *hashTagLayout) ParseStorageDetailsKey(storageDetailsKey string) (string, bool) {
+	valueKey := strings.TrimSuffix(storageDetailsKey, storageDetailsSuffix)
+	if valueKey == storageDetailsKey || !strings.HasPrefix(valueKey, hashTagOpen) || !strings.HasSuffix(valueKey, hashTagClose) {
+		return empty, false
+	}
+	return unescapeKey(valueKey[len(hashTagOpen) : len(valueKey)-len(hashTagClose)]), true
+}
+
+func escapeKey(key string) string {
+	return keyEscaper.Replace(key)
+}
+
+func unescapeKey(key string) string {
+	return keyUnescaper.Replace(key)
+}
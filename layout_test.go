@@ -0,0 +1,73 @@
+package fridge
+
+import (
+	"github.com/stretchr/testify/assert"
+	"path"
+	"testing"
+)
+
+func TestKeyLayout_Suffix(t *testing.T) {
+	keyLayout := NewSuffixLayout()
+
+	assert.Equal(t, keyLayout.ValueKey("food"), "food")
+	assert.Equal(t, keyLayout.StorageDetailsKey("food"), "food.config")
+	assert.Equal(t, keyLayout.ValueKey("food.config"), "food%2Econfig")
+	assert.NotEqual(t, keyLayout.ValueKey("food.config"), keyLayout.StorageDetailsKey("food"))
+
+	key, ok := keyLayout.ParseStorageDetailsKey(keyLayout.StorageDetailsKey("food.config"))
+	assert.True(t, ok)
+	assert.Equal(t, key, "food.config")
+
+	_, ok = keyLayout.ParseStorageDetailsKey("food")
+	assert.False(t, ok)
+}
+
+func TestKeyLayout_Legacy(t *testing.T) {
+	keyLayout := NewLegacyLayout()
+
+	assert.Equal(t, keyLayout.ValueKey("food.config"), "food.config")
+	assert.Equal(t, keyLayout.StorageDetailsKey("food"), "food.config")
+
+	key, ok := keyLayout.ParseStorageDetailsKey("food.config")
+	assert.True(t, ok)
+	assert.Equal(t, key, "food")
+}
+
+func TestKeyLayout_Prefix(t *testing.T) {
+	keyLayout := NewPrefixLayout()
+
+	assert.Equal(t, keyLayout.ValueKey("food"), "food")
+	assert.Equal(t, keyLayout.StorageDetailsKey("food"), "config:food")
+	assert.NotEqual(t, keyLayout.ValueKey("config:food"), keyLayout.StorageDetailsKey("food"))
+
+	key, ok := keyLayout.ParseStorageDetailsKey(keyLayout.StorageDetailsKey("config:food"))
+	assert.True(t, ok)
+	assert.Equal(t, key, "config:food")
+}
+
+func TestKeyLayout_HashTag(t *testing.T) {
+	keyLayout := NewHashTagLayout()
+
+	assert.Equal(t, keyLayout.ValueKey("food"), "{food}")
+	assert.Equal(t, keyLayout.StorageDetailsKey("food"), "{food}.config")
+	assert.Equal(t, keyLayout.ValueKey("{food}"), "{%7Bfood%7D}")
+
+	key, ok := keyLayout.ParseStorageDetailsKey(keyLayout.StorageDetailsKey("{food}.config"))
+	assert.True(t, ok)
+	assert.Equal(t, key, "{food}.config")
+
+	_, ok = keyLayout.ParseStorageDetailsKey("{food}")
+	assert.False(t, ok)
+}
+
+func TestKeyLayout_Patterns(t *testing.T) {
+	for _, keyLayout := range []KeyLayout{NewSuffixLayout(), NewPrefixLayout(), NewHashTagLayout()} {
+		matched, err := path.Match(keyLayout.StorageDetailsPattern(), keyLayout.StorageDetailsKey("food"))
+		assert.Nil(t, err)
+		assert.True(t, matched)
+
+		matched, err = path.Match(keyLayout.StorageDetailsPattern(), keyLayout.ValueKey("food.config"))
+		assert.Nil(t, err)
+		assert.False(t, matched)
+	}
+}
@@ -0,0 +1,77 @@
+package fridge
+
+import (
+	"path"
+	"strings"
+)
+
+const (
+	globCharacters = "*?[\\"
+)
+
+// Loader loads the fresh value of a key
+type Loader func(key string) (string, error)
+
+// ConditionalLoader loads the fresh value of a key given its cached value and validator, like a ConditionalRestock
+type ConditionalLoader func(key string, cachedValue string, validator string) (value string, newValidator string, modified bool, err error)
+
+type registeredLoader struct {
+	pattern string
+	loader  ConditionalLoader
+}
+
+// RegisterLoader registers a loader for keys matching a pattern, which is a glob when it has any of the characters
+// "*?[\" and a key prefix otherwise. Loaders are used when no restock function is given, and the first registered match wins
+func (c *Client) RegisterLoader(pattern string, loader Loader) {
+	c.RegisterConditionalLoader(pattern, unconditionalLoader(loader))
+}
+
+// RegisterConditionalLoader registers a loader like RegisterLoader, which is given the cached value and validator
+func (c *Client) RegisterConditionalLoader(pattern string, loader ConditionalLoader) {
+	c.loadersMutex.Lock()
+	defer c.loadersMutex.Unlock()
+
+	c.loaders = append(c.loaders, &registeredLoader{pattern: pattern, loader: loader})
+}
+
+// findRestock returns a restock function that calls the loader registered for a key, or nil when there is none
+func (c *Client) findRestock(key string) ConditionalRestock {
+	c.loadersMutex.RLock()
+	defer c.loadersMutex.RUnlock()
+
+	for _, registered := range c.loaders {
+		if !matchKeyPattern(registered.pattern, key) {
+			continue
+		}
+		return registered.loader.restock(key)
+	}
+	return nil
+}
+
+// restock returns a restock function that calls the loader for a key
+func (loader ConditionalLoader) restock(key string) ConditionalRestock {
+	return func(cachedValue string, validator string) (string, string, bool, error) {
+		return loader(key, cachedValue, validator)
+	}
+}
+
+// unconditionalLoader adapts a loader that ignores validators, or returns nil when there is none
+func unconditionalLoader(loader Loader) ConditionalLoader {
+	if loader == nil {
+		return nil
+	}
+
+	return func(key string, _ string, _ string) (string, string, bool, error) {
+		value, err := loader(key)
+		return value, empty, true, err
+	}
+}
+
+func matchKeyPattern(pattern string, key string) bool {
+	if !strings.ContainsAny(pattern, globCharacters) {
+		return strings.HasPrefix(key, pattern)
+	}
+
+	matched, err := path.Match(pattern, key)
+	return err == nil && matched
+}
@@ -0,0 +1,102 @@
+package fridge
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLoader_Match(t *testing.T) {
+	assert.True(t, matchKeyPattern("food:", "food:pizza"))
+	assert.False(t, matchKeyPattern("food:", "drink:milk"))
+	assert.True(t, matchKeyPattern("food:*", "food:pizza"))
+	assert.True(t, matchKeyPattern("*:pizza", "food:pizza"))
+	assert.False(t, matchKeyPattern("*:pizza", "food:pasta"))
+	assert.False(t, matchKeyPattern("[", "["))
+}
+
+func TestClient_RegisterLoader(t *testing.T) {
+	cache := newMemoryCache()
+	client := NewClient(cache)
+	defer client.Close()
+
+	client.RegisterLoader("food:*", func(key string) (string, error) {
+		return "Hot " + key, nil
+	})
+	client.RegisterLoader("food:", func(key string) (string, error) {
+		return "Cold " + key, nil
+	})
+
+	assert.Nil(t, client.Put("food:pizza", "Pizza"))
+	assert.Nil(t, cache.Remove("food%3Apizza"))
+
+	value, found, err := client.Get("food:pizza")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, value, "Hot food:pizza")
+
+	assert.Nil(t, cache.Remove("food%3Apizza"))
+
+	value, found, err = client.Get("food:pizza", WithRestock(func() (string, error) {
+		return "Pizza", nil
+	}))
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, value, "Pizza")
+
+	summary, err := client.Warm(context.Background(), []string{"food:pasta", "drink:milk"}, nil, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, summary.Loaded, []string{"food:pasta"})
+	assert.Contains(t, summary.Failed, "drink:milk")
+	assert.Equal(t, cache.memory["food%3Apasta"], "Hot food:pasta")
+
+	assert.Nil(t, client.Schedule(context.Background(), "food:pizza", nil))
+	assert.NotNil(t, client.Schedule(context.Background(), "drink:milk", nil))
+}
+
+func TestClient_RegisterConditionalLoader(t *testing.T) {
+	cache := newMemoryCache()
+	client := NewClient(cache)
+	defer client.Close()
+
+	validators := make(chan string, 10)
+	client.RegisterConditionalLoader("food:", func(key string, cachedValue string, validator string) (string, string, bool, error) {
+		validators <- validator
+		if validator == `"v1"` {
+			return empty, `"v2"`, false, nil
+		}
+		return "Hot " + key, `"v1"`, true, nil
+	})
+
+	assert.Nil(t, client.Put("food:pizza", "Pizza", WithValidator(`"v1"`)))
+	_, err := client.Invalidate("food:pizza", InvalidateUseBy)
+	assert.Nil(t, err)
+
+	summary, err := client.Warm(context.Background(), []string{"food:pizza", "food:pasta"}, nil, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, summary.Loaded, []string{"food:pizza", "food:pasta"})
+	assert.Equal(t, <-validators, `"v1"`)
+	assert.Equal(t, <-validators, empty)
+	assert.Equal(t, cache.memory["food%3Apasta"], "Hot food:pasta")
+
+	result, err := client.Peek("food:pizza")
+	assert.Nil(t, err)
+	assert.Equal(t, result.State, Fresh)
+	assert.Equal(t, result.Value, "Pizza")
+	assert.Equal(t, result.StorageDetails.Validator, `"v2"`)
+
+	_, err = client.Invalidate("food:pizza", InvalidateUseBy)
+	assert.Nil(t, err)
+
+	value, found, err := client.Get("food:pizza")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, value, "Hot food:pizza")
+	assert.Equal(t, <-validators, `"v2"`)
+
+	summary, err = client.WarmConditional(context.Background(), []string{"food:pie"}, func(key string, cachedValue string, validator string) (string, string, bool, error) {
+		return empty, empty, false, nil
+	}, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, summary.Failed["food:pie"].Error(), notModifiedError)
+}
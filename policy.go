@@ -0,0 +1,92 @@
+package fridge
+
+import (
+	"time"
+)
+
+// PolicyOption an option for a key pattern's policy
+type PolicyOption func(*Policy)
+
+// WithPolicyDurations sets the best by and use by durations of the keys matching a policy
+func WithPolicyDurations(bestBy time.Duration, useBy time.Duration) PolicyOption {
+	return func(policy *Policy) {
+		policy.BestBy = bestBy
+		policy.UseBy = useBy
+	}
+}
+
+// WithPolicyGracePeriod sets how long storage details outlive the "Use By" duration of the keys matching a policy
+func WithPolicyGracePeriod(gracePeriod time.Duration) PolicyOption {
+	return func(policy *Policy) {
+		policy.GracePeriod = gracePeriod
+	}
+}
+
+// WithPolicyCompression sets the compressor and threshold of the keys matching a policy
+func WithPolicyCompression(compressor Compressor, threshold int) PolicyOption {
+	return func(policy *Policy) {
+		policy.Compressor = compressor
+		policy.CompressionThreshold = threshold
+	}
+}
+
+// WithPolicyRetryInterval sets how long scheduled refreshes of the keys matching a policy wait before retrying
+func WithPolicyRetryInterval(retryInterval time.Duration) PolicyOption {
+	return func(policy *Policy) {
+		policy.RetryInterval = retryInterval
+	}
+}
+
+// WithPolicy adds a policy for keys matching a pattern, which is a glob when it has any of the characters "*?[\"
+// and a key prefix otherwise. Settings that a policy does not set fall back to the defaults, and the first added match wins
+func WithPolicy(pattern string, options ...PolicyOption) DefaultsOption {
+	return func(defaults *Defaults) {
+		defaults.Policies = append(defaults.Policies, &PolicyRule{Pattern: pattern, Options: options})
+	}
+}
+
+// PolicyRule maps a key pattern to policy options
+type PolicyRule struct {
+	Pattern string
+	Options []PolicyOption
+}
+
+// Policy contains the settings resolved for a key, and the pattern of the rule that matched it or empty for the defaults
+type Policy struct {
+	Pattern              string
+	BestBy               time.Duration
+	UseBy                time.Duration
+	GracePeriod          time.Duration
+	Compressor           Compressor
+	CompressionThreshold int
+	RetryInterval        time.Duration
+}
+
+// Policy returns the settings resolved for a key and which rule matched it
+func (c *Client) Policy(key string) *Policy {
+	return c.defaults.policy(key)
+}
+
+func (d *Defaults) policy(key string) *Policy {
+	policy := &Policy{
+		BestBy:               d.BestBy,
+		UseBy:                d.UseBy,
+		GracePeriod:          d.GracePeriod,
+		Compressor:           d.Compressor,
+		CompressionThreshold: d.CompressionThreshold,
+		RetryInterval:        defaultScheduleRetryInterval,
+	}
+
+	for _, rule := range d.Policies {
+		if !matchKeyPattern(rule.Pattern, key) {
+			continue
+		}
+
+		policy.Pattern = rule.Pattern
+		for _, option := range rule.Options {
+			option(policy)
+		}
+		break
+	}
+	return policy
+}
@@ -0,0 +1,67 @@
+package fridge
+
+import (
+	"compress/gzip"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_Policy(t *testing.T) {
+	client := NewClient(newMemoryCache(),
+		WithDefaultDurations(time.Minute, time.Hour),
+		WithPolicy("reports:*", WithPolicyDurations(time.Hour, 24*time.Hour), WithPolicyRetryInterval(time.Minute)),
+		WithPolicy("reports:", WithPolicyGracePeriod(time.Second)),
+	)
+	defer client.Close()
+
+	policy := client.Policy("food")
+	assert.Equal(t, policy.Pattern, empty)
+	assert.Equal(t, policy.BestBy, time.Minute)
+	assert.Equal(t, policy.UseBy, time.Hour)
+	assert.Equal(t, policy.GracePeriod, defaultGracePeriod)
+	assert.Equal(t, policy.RetryInterval, defaultScheduleRetryInterval)
+
+	policy = client.Policy("reports:daily")
+	assert.Equal(t, policy.Pattern, "reports:*")
+	assert.Equal(t, policy.BestBy, time.Hour)
+	assert.Equal(t, policy.UseBy, 24*time.Hour)
+	assert.Equal(t, policy.GracePeriod, defaultGracePeriod)
+	assert.Equal(t, policy.RetryInterval, time.Minute)
+}
+
+func TestClient_PolicyPut(t *testing.T) {
+	cache := newMemoryCache()
+	client := NewClient(cache,
+		WithPolicy("reports:", WithPolicyDurations(time.Minute, time.Hour), WithPolicyGracePeriod(time.Second)),
+		WithPolicy("logs:", WithPolicyCompression(NewGzipCompressor(gzip.BestSpeed), 10)),
+	)
+	defer client.Close()
+
+	assert.Nil(t, client.Put("reports:daily", "Report"))
+	assert.Equal(t, cache.timeouts["reports%3Adaily"], time.Hour)
+	assert.Equal(t, cache.timeouts["reports%3Adaily.config"], time.Hour+time.Second)
+
+	assert.Nil(t, client.Put("reports:weekly", "Report", WithDurations(time.Second, time.Minute)))
+	assert.Equal(t, cache.timeouts["reports%3Aweekly"], time.Minute)
+
+	value := strings.Repeat("Log", 100)
+	assert.Nil(t, client.Put("logs:daily", value))
+	assert.True(t, len(cache.memory["logs%3Adaily"]) < len(value))
+
+	cachedValue, found, err := client.Get("logs:daily")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, cachedValue, value)
+
+	delete(cache.memory, "reports%3Aweekly")
+
+	cachedValue, found, err = client.Get("reports:weekly", WithRestock(func() (string, error) {
+		return "New Report", nil
+	}))
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, cachedValue, "New Report")
+	assert.Equal(t, cache.timeouts["reports%3Aweekly"], time.Hour)
+}
@@ -2,13 +2,56 @@ package fridge
 
 import (
 	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/garyburd/redigo/redis"
 	"github.com/shomali11/xredis"
 	"time"
 )
 
+const (
+	defaultRedisHost                  = "localhost"
+	defaultRedisPort                  = 6379
+	defaultRedisNetwork               = "tcp"
+	defaultRedisConnectTimeout        = time.Second
+	defaultRedisWriteTimeout          = time.Second
+	defaultRedisReadTimeout           = time.Second
+	defaultRedisConnectionIdleTimeout = 240 * time.Second
+	defaultRedisConnectionMaxIdle     = 100
+	defaultRedisConnectionMaxActive   = 10000
+	defaultRedisReplicaReads          = true
+
+	getCommand               = "GET"
+	setCommand               = "SET"
+	delCommand               = "DEL"
+	scanCommand              = "SCAN"
+	pttlCommand              = "PTTL"
+	pexpireCommand           = "PEXPIRE"
+	persistCommand           = "PERSIST"
+	saddCommand              = "SADD"
+	smembersCommand          = "SMEMBERS"
+	sremCommand              = "SREM"
+	incrCommand              = "INCR"
+	existsCommand            = "EXISTS"
+	pingCommand              = "PING"
+	authCommand              = "AUTH"
+	selectCommand            = "SELECT"
+	matchOption              = "MATCH"
+	expireMillisecondsOption = "PX"
+
+	missingKeyTTL    = -2
+	persistentKeyTTL = -1
+
+	addressFormat        = "%s:%d"
+	negativeTimeoutError = "timeout cannot be negative"
+)
+
 // RedisOption an option for a redis option
 type RedisOption func(*RedisSettings)
 
+// CredentialsProvider returns the username and password used to authenticate a new connection
+type CredentialsProvider func() (username string, password string, err error)
+
 // WithHost sets redis host
 func WithHost(host string) RedisOption {
 	return func(redisSettings *RedisSettings) {
@@ -30,6 +73,21 @@ func WithPassword(password string) RedisOption {
 	}
 }
 
+// WithUsername sets redis ACL username
+func WithUsername(username string) RedisOption {
+	return func(redisSettings *RedisSettings) {
+		redisSettings.Username = username
+	}
+}
+
+// WithCredentialsProvider sets a callback consulted for the username and password of every new redis connection.
+// It takes precedence over the static username and password
+func WithCredentialsProvider(credentialsProvider CredentialsProvider) RedisOption {
+	return func(redisSettings *RedisSettings) {
+		redisSettings.CredentialsProvider = credentialsProvider
+	}
+}
+
 // WithDatabase sets redis database
 func WithDatabase(database int) RedisOption {
 	return func(redisSettings *RedisSettings) {
@@ -100,6 +158,13 @@ func WithTlsConfig(tlsConfig *tls.Config) RedisOption {
 	}
 }
 
+// WithUseTls sets whether redis connections use tls
+func WithUseTls(useTls bool) RedisOption {
+	return func(redisSettings *RedisSettings) {
+		redisSettings.UseTls = useTls
+	}
+}
+
 // WithTlsSkipVerify sets redis tls skip verification
 func WithTlsSkipVerify(tlsSkipVerify bool) RedisOption {
 	return func(redisSettings *RedisSettings) {
@@ -114,87 +179,351 @@ func WithTestOnBorrowPeriod(testOnBorrowPeriod time.Duration) RedisOption {
 	}
 }
 
-// RedisSettings contains redis settings
+// RedisSettings contains redis settings shared by RedisCache, SentinelCache and ClusterCache.
+// When sentinel addresses are set, the master and replicas are discovered through sentinel
+// instead of connecting to the host and port directly
 type RedisSettings struct {
-	Host                  string
-	Port                  int
-	Password              string
-	Database              int
-	Network               string
-	ConnectTimeout        time.Duration
-	WriteTimeout          time.Duration
-	ReadTimeout           time.Duration
-	ConnectionIdleTimeout time.Duration
-	ConnectionMaxIdle     int
-	ConnectionMaxActive   int
-	ConnectionWait        bool
-	TlsConfig             *tls.Config
-	TlsSkipVerify         bool
-	TestOnBorrowPeriod    time.Duration
+	Host                        string
+	Port                        int
+	Username                    string
+	Password                    string
+	CredentialsProvider         CredentialsProvider
+	Database                    int
+	Network                     string
+	ConnectTimeout              time.Duration
+	WriteTimeout                time.Duration
+	ReadTimeout                 time.Duration
+	ConnectionIdleTimeout       time.Duration
+	ConnectionMaxIdle           int
+	ConnectionMaxActive         int
+	ConnectionWait              bool
+	UseTls                      bool
+	TlsConfig                   *tls.Config
+	TlsSkipVerify               bool
+	TestOnBorrowPeriod          time.Duration
+	SentinelAddresses           []string
+	SentinelMasterName          string
+	SentinelUsername            string
+	SentinelPassword            string
+	SentinelCredentialsProvider CredentialsProvider
+	ReplicaReads                bool
+	MaxReplicationLag           time.Duration
+	ClusterAddresses            []string
+	ClusterMaxRedirects         int
 }
 
 // NewRedisCache creates a new redis client
 func NewRedisCache(options ...RedisOption) *RedisCache {
-	settings := &RedisSettings{}
-	for _, option := range options {
-		option(settings)
-	}
-
-	redisOptions := &xredis.Options{
-		Host:                  settings.Host,
-		Port:                  settings.Port,
-		Password:              settings.Password,
-		Database:              settings.Database,
-		Network:               settings.Network,
-		ConnectTimeout:        settings.ConnectTimeout,
-		WriteTimeout:          settings.WriteTimeout,
-		ReadTimeout:           settings.ReadTimeout,
-		ConnectionIdleTimeout: settings.ConnectionIdleTimeout,
-		ConnectionMaxIdle:     settings.ConnectionMaxIdle,
-		ConnectionMaxActive:   settings.ConnectionMaxActive,
-		ConnectionWait:        settings.ConnectionWait,
-		TlsConfig:             settings.TlsConfig,
-		TlsSkipVerify:         settings.TlsSkipVerify,
-		TestOnBorrowPeriod:    settings.TestOnBorrowPeriod,
-	}
-	return &RedisCache{client: xredis.SetupClient(redisOptions)}
+	return &RedisCache{redisClient: newRedisClient(newRedisSettings(options...))}
 }
 
 // RedisCache contains redis client
 type RedisCache struct {
-	client *xredis.Client
+	*redisClient
+}
+
+// redisClient is the redis backed cache shared by RedisCache and SentinelCache.
+// Writes go through client while reads go through readClient, which may point to replicas
+type redisClient struct {
+	client     *xredis.Client
+	readClient *xredis.Client
 }
 
 // Get a value by key
-func (c *RedisCache) Get(key string) (string, bool, error) {
-	return c.client.Get(key)
+func (c *redisClient) Get(key string) (string, bool, error) {
+	return c.readClient.Get(key)
 }
 
 // Set a key value pair
-func (c *RedisCache) Set(key string, value string, timeout time.Duration) error {
-	seconds := int64(timeout.Seconds())
-	if seconds == 0 {
+func (c *redisClient) Set(key string, value string, timeout time.Duration) error {
+	milliseconds, err := timeoutMilliseconds(timeout)
+	if err != nil {
+		return err
+	}
+
+	if milliseconds == 0 {
 		_, err := c.client.Set(key, value)
 		return err
 	}
 
-	_, err := c.client.SetEx(key, value, seconds)
+	connection := c.client.GetConnection()
+	defer connection.Close()
+
+	_, err = connection.Do(setCommand, key, value, expireMillisecondsOption, milliseconds)
 	return err
 }
 
 // Remove a key
-func (c *RedisCache) Remove(key string) error {
+func (c *redisClient) Remove(key string) error {
 	_, err := c.client.Del(key)
 	return err
 }
 
+// Scan keys matching a pattern starting from a cursor
+func (c *redisClient) Scan(cursor int64, pattern string) (int64, []string, error) {
+	return c.client.Scan(cursor, pattern)
+}
+
+// RemoveAll removes a batch of keys
+func (c *redisClient) RemoveAll(keys ...string) error {
+	_, err := c.client.Del(keys...)
+	return err
+}
+
+// TTL returns the remaining time to live of a key, or NoExpiration when it does not expire
+func (c *redisClient) TTL(key string) (time.Duration, bool, error) {
+	connection := c.readClient.GetConnection()
+	defer connection.Close()
+
+	return parseTTL(redis.Int64(connection.Do(pttlCommand, key)))
+}
+
+// AddMembers adds members to a set, which expires after the longest timeout it was given, or never when given NoExpiration
+func (c *redisClient) AddMembers(key string, timeout time.Duration, members ...string) error {
+	connection := c.client.GetConnection()
+	defer connection.Close()
+
+	return addMembers(connection.Do, key, timeout, members...)
+}
+
+// Members returns the members of a set
+func (c *redisClient) Members(key string) ([]string, error) {
+	connection := c.client.GetConnection()
+	defer connection.Close()
+
+	return redis.Strings(connection.Do(smembersCommand, key))
+}
+
+// RemoveMembers removes members from a set
+func (c *redisClient) RemoveMembers(key string, members ...string) error {
+	connection := c.client.GetConnection()
+	defer connection.Close()
+
+	_, err := connection.Do(sremCommand, redis.Args{}.Add(key).AddFlat(members)...)
+	return err
+}
+
+// Expire sets when a key expires, or that it never does when given NoExpiration, and returns whether the key exists
+func (c *redisClient) Expire(key string, timeout time.Duration) (bool, error) {
+	connection := c.client.GetConnection()
+	defer connection.Close()
+
+	return expire(connection.Do, key, timeout)
+}
+
+// Increment increments a counter and returns its new value
+func (c *redisClient) Increment(key string) (int64, error) {
+	return c.client.Incr(key)
+}
+
 // Ping to test connectivity
-func (c *RedisCache) Ping() error {
+func (c *redisClient) Ping() error {
 	_, err := c.client.Ping()
 	return err
 }
 
 // Close to close resources
-func (c *RedisCache) Close() error {
-	return c.client.Close()
+func (c *redisClient) Close() error {
+	err := c.client.Close()
+	if err != nil || c.readClient == c.client {
+		return err
+	}
+	return c.readClient.Close()
+}
+
+// addMembers adds members to a set and extends its expiration so that it outlives every member
+func addMembers(do func(command string, arguments ...interface{}) (interface{}, error), key string, timeout time.Duration, members ...string) error {
+	milliseconds, err := timeoutMilliseconds(timeout)
+	if err != nil {
+		return err
+	}
+
+	ttl, found, err := parseTTL(redis.Int64(do(pttlCommand, key)))
+	if err != nil {
+		return err
+	}
+
+	_, err = do(saddCommand, redis.Args{}.Add(key).AddFlat(members)...)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case milliseconds == 0:
+		_, err = do(persistCommand, key)
+	case !found || (ttl != NoExpiration && ttl < timeout):
+		_, err = do(pexpireCommand, key, milliseconds)
+	}
+	return err
+}
+
+// expire sets the expiration of a key with PEXPIRE, or removes it with PERSIST, which does not tell a missing key
+// from a persistent one, so that it is followed by EXISTS when nothing was removed
+func expire(do func(command string, arguments ...interface{}) (interface{}, error), key string, timeout time.Duration) (bool, error) {
+	milliseconds, err := timeoutMilliseconds(timeout)
+	if err != nil {
+		return false, err
+	}
+
+	if milliseconds > 0 {
+		return redis.Bool(do(pexpireCommand, key, milliseconds))
+	}
+
+	persisted, err := redis.Bool(do(persistCommand, key))
+	if err != nil || persisted {
+		return persisted, err
+	}
+	return redis.Bool(do(existsCommand, key))
+}
+
+// parseTTL converts the reply of PTTL, which is -2 for missing keys and -1 for keys without expiration
+func parseTTL(milliseconds int64, err error) (time.Duration, bool, error) {
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch milliseconds {
+	case missingKeyTTL:
+		return 0, false, nil
+	case persistentKeyTTL:
+		return NoExpiration, true, nil
+	}
+	return time.Duration(milliseconds) * time.Millisecond, true, nil
+}
+
+func newRedisSettings(options ...RedisOption) *RedisSettings {
+	settings := &RedisSettings{
+		Host:                  defaultRedisHost,
+		Port:                  defaultRedisPort,
+		Network:               defaultRedisNetwork,
+		ConnectTimeout:        defaultRedisConnectTimeout,
+		WriteTimeout:          defaultRedisWriteTimeout,
+		ReadTimeout:           defaultRedisReadTimeout,
+		ConnectionIdleTimeout: defaultRedisConnectionIdleTimeout,
+		ConnectionMaxIdle:     defaultRedisConnectionMaxIdle,
+		ConnectionMaxActive:   defaultRedisConnectionMaxActive,
+		ReplicaReads:          defaultRedisReplicaReads,
+		ClusterMaxRedirects:   defaultClusterMaxRedirects,
+	}
+
+	for _, option := range options {
+		option(settings)
+	}
+	return settings
+}
+
+func newRedisClient(settings *RedisSettings) *redisClient {
+	if len(settings.SentinelAddresses) > 0 {
+		return newSentinelRedisClient(settings)
+	}
+
+	address := fmt.Sprintf(addressFormat, settings.Host, settings.Port)
+	dial := func() (redis.Conn, error) {
+		return dialRedis(settings, address)
+	}
+
+	client := xredis.NewClient(newRedisPool(settings, dial, testOnBorrow(settings, nil)))
+	return &redisClient{client: client, readClient: client}
+}
+
+func newRedisPool(settings *RedisSettings, dial func() (redis.Conn, error), testOnBorrow func(redis.Conn, time.Time) error) *redis.Pool {
+	return &redis.Pool{
+		IdleTimeout:  settings.ConnectionIdleTimeout,
+		MaxActive:    settings.ConnectionMaxActive,
+		MaxIdle:      settings.ConnectionMaxIdle,
+		Wait:         settings.ConnectionWait,
+		Dial:         dial,
+		TestOnBorrow: testOnBorrow,
+	}
+}
+
+// testOnBorrow runs check on every borrowed connection, and pings connections idle for longer than the test on borrow period
+func testOnBorrow(settings *RedisSettings, check func(redis.Conn) error) func(redis.Conn, time.Time) error {
+	period := settings.TestOnBorrowPeriod
+
+	return func(connection redis.Conn, t time.Time) error {
+		if check != nil {
+			err := check(connection)
+			if err != nil {
+				return err
+			}
+		}
+
+		if time.Since(t) < period {
+			return nil
+		}
+
+		_, err := connection.Do(pingCommand)
+		return err
+	}
+}
+
+func dialRedis(settings *RedisSettings, address string) (redis.Conn, error) {
+	connection, err := redis.Dial(settings.Network, address, dialOptions(settings)...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = authenticate(connection, settings.Username, settings.Password, settings.CredentialsProvider)
+	if err != nil {
+		connection.Close()
+		return nil, err
+	}
+
+	if settings.Database != 0 {
+		_, err = connection.Do(selectCommand, settings.Database)
+		if err != nil {
+			connection.Close()
+			return nil, err
+		}
+	}
+	return connection, nil
+}
+
+func dialOptions(settings *RedisSettings) []redis.DialOption {
+	return []redis.DialOption{
+		redis.DialConnectTimeout(settings.ConnectTimeout),
+		redis.DialWriteTimeout(settings.WriteTimeout),
+		redis.DialReadTimeout(settings.ReadTimeout),
+		redis.DialUseTLS(settings.UseTls),
+		redis.DialTLSSkipVerify(settings.TlsSkipVerify),
+		redis.DialTLSConfig(settings.TlsConfig),
+	}
+}
+
+// authenticate sends AUTH on a new connection, preferring the credentials provider over the static credentials
+func authenticate(connection redis.Conn, username string, password string, credentialsProvider CredentialsProvider) error {
+	if credentialsProvider != nil {
+		var err error
+		username, password, err = credentialsProvider()
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(password) == 0 && len(username) == 0 {
+		return nil
+	}
+
+	if len(username) == 0 {
+		_, err := connection.Do(authCommand, password)
+		return err
+	}
+
+	_, err := connection.Do(authCommand, username, password)
+	return err
+}
+
+// timeoutMilliseconds converts a timeout to milliseconds, rounding sub-millisecond timeouts up.
+// Only NoExpiration converts to zero
+func timeoutMilliseconds(timeout time.Duration) (int64, error) {
+	if timeout < 0 {
+		return 0, errors.New(negativeTimeoutError)
+	}
+
+	milliseconds := int64(timeout / time.Millisecond)
+	if timeout%time.Millisecond != 0 {
+		milliseconds++
+	}
+	return milliseconds, nil
 }
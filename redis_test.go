@@ -2,6 +2,8 @@ package fridge
 
 import (
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"testing"
 	"time"
@@ -141,3 +143,150 @@ func TestRedisClient_WithTestOnBorrowPeriod(t *testing.T) {
 
 	assert.Equal(t, redisSettings.TestOnBorrowPeriod, time.Second)
 }
+
+func TestRedisClient_TimeoutMilliseconds(t *testing.T) {
+	milliseconds, err := timeoutMilliseconds(NoExpiration)
+	assert.Nil(t, err)
+	assert.Equal(t, milliseconds, int64(0))
+
+	milliseconds, err = timeoutMilliseconds(500 * time.Millisecond)
+	assert.Nil(t, err)
+	assert.Equal(t, milliseconds, int64(500))
+
+	milliseconds, err = timeoutMilliseconds(2 * time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, milliseconds, int64(2000))
+
+	milliseconds, err = timeoutMilliseconds(time.Microsecond)
+	assert.Nil(t, err)
+	assert.Equal(t, milliseconds, int64(1))
+
+	milliseconds, err = timeoutMilliseconds(1500 * time.Microsecond)
+	assert.Nil(t, err)
+	assert.Equal(t, milliseconds, int64(2))
+
+	_, err = timeoutMilliseconds(-time.Second)
+	assert.NotNil(t, err)
+}
+
+func TestRedisClient_Defaults(t *testing.T) {
+	redisSettings := newRedisSettings(WithPort(1111))
+
+	assert.Equal(t, redisSettings.Host, "localhost")
+	assert.Equal(t, redisSettings.Port, 1111)
+	assert.Equal(t, redisSettings.Network, "tcp")
+	assert.Equal(t, redisSettings.ConnectTimeout, time.Second)
+	assert.True(t, redisSettings.ReplicaReads)
+}
+
+func TestRedisClient_WithUsername(t *testing.T) {
+	redisSettings := &RedisSettings{}
+
+	redisOption := WithUsername("username")
+	redisOption(redisSettings)
+
+	assert.Equal(t, redisSettings.Username, "username")
+}
+
+func TestRedisClient_WithUseTls(t *testing.T) {
+	redisSettings := &RedisSettings{}
+
+	redisOption := WithUseTls(true)
+	redisOption(redisSettings)
+
+	assert.Equal(t, redisSettings.UseTls, true)
+}
+
+func TestRedisClient_WithCredentialsProvider(t *testing.T) {
+	redisSettings := &RedisSettings{}
+
+	redisOption := WithCredentialsProvider(func() (string, string, error) {
+		return "username", "password", nil
+	})
+	redisOption(redisSettings)
+
+	username, password, err := redisSettings.CredentialsProvider()
+	assert.Equal(t, username, "username")
+	assert.Equal(t, password, "password")
+	assert.Nil(t, err)
+}
+
+type recordingConnection struct {
+	commands [][]interface{}
+}
+
+func (c *recordingConnection) Close() error {
+	return nil
+}
+
+func (c *recordingConnection) Err() error {
+	return nil
+}
+
+func (c *recordingConnection) Do(commandName string, args ...interface{}) (interface{}, error) {
+	c.commands = append(c.commands, append([]interface{}{commandName}, args...))
+	return "OK", nil
+}
+
+func (c *recordingConnection) Send(commandName string, args ...interface{}) error {
+	return nil
+}
+
+func (c *recordingConnection) Flush() error {
+	return nil
+}
+
+func (c *recordingConnection) Receive() (interface{}, error) {
+	return nil, nil
+}
+
+func TestRedisClient_Authenticate(t *testing.T) {
+	recorder := &recordingConnection{}
+	assert.Nil(t, authenticate(recorder, "", "", nil))
+	assert.Empty(t, recorder.commands)
+
+	recorder = &recordingConnection{}
+	assert.Nil(t, authenticate(recorder, "", "password", nil))
+	assert.Equal(t, recorder.commands, [][]interface{}{{"AUTH", "password"}})
+
+	recorder = &recordingConnection{}
+	assert.Nil(t, authenticate(recorder, "username", "password", nil))
+	assert.Equal(t, recorder.commands, [][]interface{}{{"AUTH", "username", "password"}})
+
+	rotation := 0
+	credentialsProvider := func() (string, string, error) {
+		rotation++
+		return "rotated", fmt.Sprintf("password%d", rotation), nil
+	}
+
+	recorder = &recordingConnection{}
+	assert.Nil(t, authenticate(recorder, "username", "password", credentialsProvider))
+	assert.Nil(t, authenticate(recorder, "username", "password", credentialsProvider))
+	assert.Equal(t, recorder.commands, [][]interface{}{{"AUTH", "rotated", "password1"}, {"AUTH", "rotated", "password2"}})
+
+	recorder = &recordingConnection{}
+	err := authenticate(recorder, "", "", func() (string, string, error) {
+		return "", "", errors.New("vault is sealed")
+	})
+	assert.NotNil(t, err)
+	assert.Empty(t, recorder.commands)
+}
+
+func TestRedisClient_ParseTTL(t *testing.T) {
+	_, found, err := parseTTL(-2, nil)
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	ttl, found, err := parseTTL(-1, nil)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, ttl, NoExpiration)
+
+	ttl, found, err = parseTTL(1500, nil)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, ttl, 1500*time.Millisecond)
+
+	_, _, err = parseTTL(0, errors.New("connection refused"))
+	assert.NotNil(t, err)
+}
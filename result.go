@@ -0,0 +1,49 @@
+package fridge
+
+import (
+	"time"
+)
+
+// Result contains an item retrieved by GetDetailed. State is Fresh, Cold, Expired, NotFound or CorruptMetadata
+// as the item was found, before any restock. The durations are measured from the storage details of the returned value
+type Result struct {
+	Value            string
+	Found            bool
+	State            string
+	StorageDetails   *StorageDetails
+	Age              time.Duration
+	TimeToBestBy     time.Duration
+	TimeToUseBy      time.Duration
+	Restocked        bool
+	RestockScheduled bool
+}
+
+// newResult returns a result whose remaining durations are zero once they have passed or were invalidated
+func newResult(state string, value string, storageDetails *StorageDetails, now time.Time) *Result {
+	snapshot := *storageDetails
+	result := &Result{
+		Value:          value,
+		Found:          true,
+		State:          state,
+		StorageDetails: &snapshot,
+		Age:            nonNegative(now.Sub(snapshot.Timestamp)),
+		TimeToBestBy:   nonNegative(snapshot.Timestamp.Add(snapshot.BestBy).Sub(now)),
+		TimeToUseBy:    nonNegative(snapshot.Timestamp.Add(snapshot.UseBy).Sub(now)),
+	}
+
+	if snapshot.Invalidation >= InvalidateBestBy {
+		result.TimeToBestBy = 0
+	}
+
+	if snapshot.Invalidation >= InvalidateUseBy {
+		result.TimeToUseBy = 0
+	}
+	return result
+}
+
+func nonNegative(duration time.Duration) time.Duration {
+	if duration < 0 {
+		return 0
+	}
+	return duration
+}
@@ -0,0 +1,92 @@
+package fridge
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestResult_New(t *testing.T) {
+	now := time.Now().UTC()
+	storageDetails := &StorageDetails{Timestamp: now.Add(-time.Minute), BestBy: time.Hour, UseBy: 2 * time.Hour}
+
+	result := newResult(Fresh, "Pizza", storageDetails, now)
+	storageDetails.Restocking = true
+
+	assert.Equal(t, result.Value, "Pizza")
+	assert.True(t, result.Found)
+	assert.Equal(t, result.State, Fresh)
+	assert.False(t, result.StorageDetails.Restocking)
+	assert.Equal(t, result.Age, time.Minute)
+	assert.Equal(t, result.TimeToBestBy, 59*time.Minute)
+	assert.Equal(t, result.TimeToUseBy, 119*time.Minute)
+
+	result = newResult(Cold, "Pizza", storageDetails, now.Add(90*time.Minute))
+	assert.Equal(t, result.TimeToBestBy, time.Duration(0))
+	assert.Equal(t, result.TimeToUseBy, 29*time.Minute)
+}
+
+func TestResult_Invalidated(t *testing.T) {
+	now := time.Now().UTC()
+	storageDetails := &StorageDetails{Timestamp: now.Add(-time.Minute), BestBy: time.Hour, UseBy: 2 * time.Hour, Invalidation: InvalidateBestBy}
+
+	result := newResult(Cold, "Pizza", storageDetails, now)
+	assert.Equal(t, result.Age, time.Minute)
+	assert.Equal(t, result.TimeToBestBy, time.Duration(0))
+	assert.Equal(t, result.TimeToUseBy, 119*time.Minute)
+
+	storageDetails.Invalidation = InvalidateUseBy
+
+	result = newResult(Expired, "Pizza", storageDetails, now)
+	assert.Equal(t, result.TimeToBestBy, time.Duration(0))
+	assert.Equal(t, result.TimeToUseBy, time.Duration(0))
+}
+
+func TestClient_GetDetailed(t *testing.T) {
+	restock := func() (string, error) {
+		return "Hot Pizza", nil
+	}
+
+	cache := newMemoryCache()
+	client := NewClient(cache)
+	defer client.Close()
+
+	result, err := client.GetDetailed("food", WithRestock(restock))
+	assert.Nil(t, err)
+	assert.False(t, result.Found)
+	assert.Equal(t, result.State, NotFound)
+
+	assert.Nil(t, client.Put("food", "Pizza"))
+
+	result, err = client.GetDetailed("food", WithRestock(restock))
+	assert.Nil(t, err)
+	assert.True(t, result.Found)
+	assert.Equal(t, result.Value, "Pizza")
+	assert.Equal(t, result.State, Fresh)
+	assert.Equal(t, result.StorageDetails.BestBy, defaultBestBy)
+	assert.False(t, result.Restocked)
+	assert.False(t, result.RestockScheduled)
+
+	cache.memory["food.config"] = `{"Version":1,"Timestamp":"2000-01-01T00:00:00Z","BestBy":1,"UseBy":2}`
+
+	result, err = client.GetDetailed("food", WithRestock(restock))
+	assert.Nil(t, err)
+	assert.True(t, result.Found)
+	assert.Equal(t, result.Value, "Hot Pizza")
+	assert.Equal(t, result.State, Expired)
+	assert.True(t, result.Age < time.Second)
+	assert.True(t, result.Restocked)
+
+	cache.memory["food.config"] = `{"Version":1,"Timestamp":"2000-01-01T00:00:00Z","BestBy":1,"UseBy":9000000000000000000}`
+
+	restocked := make(chan bool, 1)
+	result, err = client.GetDetailed("food", WithRestock(func() (string, error) {
+		restocked <- true
+		return "Hot Pizza", nil
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, result.State, Cold)
+	assert.True(t, result.RestockScheduled)
+	assert.False(t, result.Restocked)
+	<-restocked
+}
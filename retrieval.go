@@ -10,9 +10,21 @@ func WithRestock(restock func() (string, error)) RetrievalOption {
 	}
 }
 
+// WithConditionalRestock sets a retrieval restocking option that is given the cached value and validator, and takes precedence over WithRestock
+func WithConditionalRestock(restock ConditionalRestock) RetrievalOption {
+	return func(retrievalInfo *RetrievalDetails) {
+		retrievalInfo.ConditionalRestock = restock
+	}
+}
+
+// ConditionalRestock restocks an item given its cached value and the validator it was stored with, such as an ETag or
+// a Last-Modified date. It returns the fresh value and its validator, or that the item was not modified and a new validator
+type ConditionalRestock func(cachedValue string, validator string) (value string, newValidator string, modified bool, err error)
+
 // RetrievalDetails contains retrieval information
 type RetrievalDetails struct {
-	Restock func() (string, error)
+	Restock            func() (string, error)
+	ConditionalRestock ConditionalRestock
 }
 
 func newRetrievalDetails(options ...RetrievalOption) *RetrievalDetails {
@@ -22,3 +34,15 @@ func newRetrievalDetails(options ...RetrievalOption) *RetrievalDetails {
 	}
 	return retrievalDetails
 }
+
+// unconditionalRestock adapts a restock function that ignores validators, or returns nil when there is none
+func unconditionalRestock(restock func() (string, error)) ConditionalRestock {
+	if restock == nil {
+		return nil
+	}
+
+	return func(string, string) (string, string, bool, error) {
+		value, err := restock()
+		return value, empty, true, err
+	}
+}
@@ -40,3 +40,33 @@ func TestRetrievalDetails_Override(t *testing.T) {
 	assert.Equal(t, value, "Hi")
 	assert.Nil(t, err)
 }
+
+func TestRetrievalDetails_ConditionalRestock(t *testing.T) {
+	retrievalDetails := newRetrievalDetails(WithConditionalRestock(func(cachedValue string, validator string) (string, string, bool, error) {
+		return cachedValue, validator, false, nil
+	}))
+
+	assert.NotNil(t, retrievalDetails.ConditionalRestock)
+
+	value, validator, modified, err := retrievalDetails.ConditionalRestock("Hi", `"v1"`)
+
+	assert.Equal(t, value, "Hi")
+	assert.Equal(t, validator, `"v1"`)
+	assert.False(t, modified)
+	assert.Nil(t, err)
+}
+
+func TestRetrievalDetails_UnconditionalRestock(t *testing.T) {
+	assert.Nil(t, unconditionalRestock(nil))
+
+	restock := unconditionalRestock(func() (string, error) {
+		return "Hi", nil
+	})
+
+	value, validator, modified, err := restock("Hello", `"v1"`)
+
+	assert.Equal(t, value, "Hi")
+	assert.Equal(t, validator, empty)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+}
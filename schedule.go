@@ -0,0 +1,267 @@
+package fridge
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	defaultScheduleJitter        = 0.1
+	defaultScheduleRetryInterval = 10 * time.Second
+	minimumScheduleInterval      = time.Second
+
+	nilRestockError = "no restock function was given or registered"
+)
+
+// ScheduleOption an option for a scheduled refresh
+type ScheduleOption func(*ScheduleDetails)
+
+// WithRefreshAhead sets how long before its "Best By" duration passes an item is refreshed
+func WithRefreshAhead(ahead time.Duration) ScheduleOption {
+	return func(scheduleDetails *ScheduleDetails) {
+		scheduleDetails.Ahead = ahead
+	}
+}
+
+// WithJitter sets the fraction of the "Best By" duration by which refreshes are randomly brought forward
+func WithJitter(jitter float64) ScheduleOption {
+	return func(scheduleDetails *ScheduleDetails) {
+		scheduleDetails.Jitter = jitter
+	}
+}
+
+// WithRetryInterval sets how long to wait before retrying a failed refresh
+func WithRetryInterval(retryInterval time.Duration) ScheduleOption {
+	return func(scheduleDetails *ScheduleDetails) {
+		scheduleDetails.RetryInterval = retryInterval
+	}
+}
+
+// ScheduleDetails contains scheduled refresh information
+type ScheduleDetails struct {
+	Ahead         time.Duration
+	Jitter        float64
+	RetryInterval time.Duration
+}
+
+// ScheduleStats contains the statistics of a scheduled key
+type ScheduleStats struct {
+	Refreshes   int
+	Failures    int
+	LastRefresh time.Time
+	LastError   error
+	NextRefresh time.Time
+}
+
+func newScheduleDetails(options ...ScheduleOption) *ScheduleDetails {
+	scheduleDetails := &ScheduleDetails{Jitter: defaultScheduleJitter, RetryInterval: defaultScheduleRetryInterval}
+	for _, option := range options {
+		option(scheduleDetails)
+	}
+	return scheduleDetails
+}
+
+type schedule struct {
+	key     string
+	restock ConditionalRestock
+	details *ScheduleDetails
+	cancel  context.CancelFunc
+	mutex   sync.Mutex
+	stats   ScheduleStats
+
+	// timestamp of the stored item that the current wait was computed for
+	timestamp time.Time
+}
+
+// Schedule refreshes an item in the background shortly before its "Best By" duration passes,
+// until the context is done, the key is unscheduled or the client is closed. Scheduling a key again replaces its schedule.
+// A nil restock function falls back to the loader registered for the key
+func (c *Client) Schedule(ctx context.Context, key string, restock func() (string, error), options ...ScheduleOption) error {
+	return c.ScheduleConditional(ctx, key, unconditionalRestock(restock), options...)
+}
+
+// ScheduleConditional schedules a key like Schedule, with a restock function that is given the cached value and validator
+func (c *Client) ScheduleConditional(ctx context.Context, key string, restock ConditionalRestock, options ...ScheduleOption) error {
+	if restock == nil {
+		restock = c.findRestock(key)
+	}
+
+	if restock == nil {
+		return errors.New(nilRestockError)
+	}
+
+	options = append([]ScheduleOption{WithRetryInterval(c.defaults.policy(key).RetryInterval)}, options...)
+
+	ctx, cancel := context.WithCancel(ctx)
+	entry := &schedule{key: key, restock: restock, details: newScheduleDetails(options...), cancel: cancel}
+
+	c.schedulesMutex.Lock()
+	previous, ok := c.schedules[key]
+	if ok {
+		previous.cancel()
+	}
+	c.schedules[key] = entry
+	c.schedulesMutex.Unlock()
+
+	c.schedulesGroup.Add(1)
+	go func() {
+		defer c.schedulesGroup.Done()
+		c.runSchedule(ctx, entry)
+	}()
+	return nil
+}
+
+// Unschedule stops refreshing a key in the background
+func (c *Client) Unschedule(key string) {
+	c.schedulesMutex.Lock()
+	defer c.schedulesMutex.Unlock()
+
+	entry, ok := c.schedules[key]
+	if !ok {
+		return
+	}
+
+	entry.cancel()
+	delete(c.schedules, key)
+}
+
+// ScheduleStats returns the statistics of a scheduled key
+func (c *Client) ScheduleStats(key string) (*ScheduleStats, bool) {
+	c.schedulesMutex.Lock()
+	entry, ok := c.schedules[key]
+	c.schedulesMutex.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+
+	stats := entry.stats
+	return &stats, true
+}
+
+// removeSchedule removes a stopped schedule unless it was already replaced
+func (c *Client) removeSchedule(entry *schedule) {
+	c.schedulesMutex.Lock()
+	defer c.schedulesMutex.Unlock()
+
+	if c.schedules[entry.key] == entry {
+		delete(c.schedules, entry.key)
+	}
+}
+
+func (c *Client) closeSchedules() {
+	c.schedulesMutex.Lock()
+	for key, entry := range c.schedules {
+		entry.cancel()
+		delete(c.schedules, key)
+	}
+	c.schedulesMutex.Unlock()
+
+	c.schedulesGroup.Wait()
+}
+
+func (c *Client) runSchedule(ctx context.Context, entry *schedule) {
+	defer c.removeSchedule(entry)
+
+	var wait time.Duration
+	for {
+		entry.setNextRefresh(time.Now().UTC().Add(wait))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		var err error
+		wait, err = c.refresh(entry)
+		if err != nil {
+			entry.setFailure(err)
+			wait = entry.details.RetryInterval
+		}
+	}
+}
+
+// refresh restocks an item that is missing or due and returns how long to wait before the next refresh.
+// An item stored since the last wait was computed, such as one restocked elsewhere, is waited on instead
+func (c *Client) refresh(entry *schedule) (time.Duration, error) {
+	storageDetails, found, err := c.dao.GetStorageDetails(entry.key)
+	if err != nil {
+		_, ok := err.(*CorruptMetadataError)
+		if !ok {
+			return 0, err
+		}
+	}
+
+	if !found {
+		storageDetails = c.newStorageDetails(entry.key)
+	}
+
+	cachedValue, found, err := c.dao.Load(entry.key, storageDetails)
+	if err != nil {
+		return 0, err
+	}
+
+	if !found {
+		storageDetails.Validator = empty
+	}
+
+	if found && !storageDetails.Timestamp.Equal(entry.timestamp) {
+		entry.timestamp = storageDetails.Timestamp
+		wait := entry.details.refreshDelay(storageDetails)
+		if wait > 0 {
+			return wait, nil
+		}
+	}
+
+	_, _, _, err = c.restock(entry.key, cachedValue, found, storageDetails, entry.restock)
+	if err != nil {
+		return 0, err
+	}
+
+	entry.setRefresh()
+	return minimumScheduleInterval, nil
+}
+
+// refreshDelay returns how long until an item should be refreshed, brought forward by a random jitter
+func (d *ScheduleDetails) refreshDelay(storageDetails *StorageDetails) time.Duration {
+	if storageDetails.Invalidation > 0 {
+		return 0
+	}
+
+	jitter := time.Duration(rand.Float64() * d.Jitter * float64(storageDetails.BestBy))
+	refreshAt := storageDetails.Timestamp.Add(storageDetails.BestBy - d.Ahead - jitter)
+	return refreshAt.Sub(time.Now().UTC())
+}
+
+func (s *schedule) setNextRefresh(nextRefresh time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.stats.NextRefresh = nextRefresh
+}
+
+func (s *schedule) setRefresh() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.stats.Refreshes++
+	s.stats.LastRefresh = time.Now().UTC()
+	s.stats.LastError = nil
+}
+
+func (s *schedule) setFailure(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.stats.Failures++
+	s.stats.LastError = err
+}
@@ -0,0 +1,173 @@
+package fridge
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestScheduleDetails_New(t *testing.T) {
+	scheduleDetails := newScheduleDetails()
+
+	assert.Equal(t, scheduleDetails.Ahead, time.Duration(0))
+	assert.Equal(t, scheduleDetails.Jitter, defaultScheduleJitter)
+	assert.Equal(t, scheduleDetails.RetryInterval, defaultScheduleRetryInterval)
+
+	scheduleDetails = newScheduleDetails(WithRefreshAhead(time.Minute), WithJitter(0.5), WithRetryInterval(time.Second))
+
+	assert.Equal(t, scheduleDetails.Ahead, time.Minute)
+	assert.Equal(t, scheduleDetails.Jitter, 0.5)
+	assert.Equal(t, scheduleDetails.RetryInterval, time.Second)
+}
+
+func TestScheduleDetails_RefreshDelay(t *testing.T) {
+	storageDetails := &StorageDetails{Timestamp: time.Now().UTC(), BestBy: time.Hour}
+
+	delay := newScheduleDetails(WithJitter(0), WithRefreshAhead(time.Minute)).refreshDelay(storageDetails)
+	assert.True(t, delay > 58*time.Minute && delay <= 59*time.Minute)
+
+	delay = newScheduleDetails(WithJitter(0.5)).refreshDelay(storageDetails)
+	assert.True(t, delay > 29*time.Minute && delay <= time.Hour)
+}
+
+func TestClient_Schedule(t *testing.T) {
+	cache := newMemoryCache()
+	client := NewClient(cache)
+	defer client.Close()
+
+	assert.Nil(t, client.Put("food", "Pizza", WithDurations(10*time.Millisecond, time.Hour)))
+
+	refreshed := make(chan bool, 1)
+	restock := func() (string, error) {
+		refreshed <- true
+		return "Hot Pizza", nil
+	}
+
+	assert.NotNil(t, client.Schedule(context.Background(), "food", nil))
+	assert.Nil(t, client.Schedule(context.Background(), "food", restock, WithJitter(0)))
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		assert.Fail(t, "item was not refreshed")
+	}
+
+	assert.True(t, eventually(func() bool {
+		stats, ok := client.ScheduleStats("food")
+		return ok && stats.Refreshes == 1
+	}))
+
+	value, found, err := client.Get("food")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, value, "Hot Pizza")
+
+	client.Unschedule("food")
+
+	_, ok := client.ScheduleStats("food")
+	assert.False(t, ok)
+}
+
+func TestClient_ScheduleFailure(t *testing.T) {
+	cache := newMemoryCache()
+	client := NewClient(cache)
+	defer client.Close()
+
+	restock := func() (string, error) {
+		return empty, errors.New("out of stock")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.Nil(t, client.Schedule(ctx, "food", restock, WithRetryInterval(time.Millisecond)))
+
+	assert.True(t, eventually(func() bool {
+		stats, _ := client.ScheduleStats("food")
+		return stats.Failures > 1 && stats.LastError != nil
+	}))
+
+	cancel()
+
+	assert.True(t, eventually(func() bool {
+		_, ok := client.ScheduleStats("food")
+		return !ok
+	}))
+}
+
+func TestClient_ScheduleReplaced(t *testing.T) {
+	cache := newMemoryCache()
+	client := NewClient(cache)
+	defer client.Close()
+
+	restock := func() (string, error) {
+		return "Pizza", nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.Nil(t, client.Schedule(ctx, "food", restock))
+	assert.Nil(t, client.Schedule(context.Background(), "food", restock))
+	cancel()
+
+	time.Sleep(10 * time.Millisecond)
+
+	_, ok := client.ScheduleStats("food")
+	assert.True(t, ok)
+}
+
+func TestClient_ScheduleConditional(t *testing.T) {
+	cache := newMemoryCache()
+	client := NewClient(cache)
+	defer client.Close()
+
+	assert.Nil(t, client.Put("food", "Pizza", WithDurations(10*time.Millisecond, time.Hour), WithValidator(`"v1"`)))
+
+	validators := make(chan string, 1)
+	restock := func(cachedValue string, validator string) (string, string, bool, error) {
+		select {
+		case validators <- validator:
+		default:
+		}
+		return empty, `"v2"`, false, nil
+	}
+
+	assert.Nil(t, client.ScheduleConditional(context.Background(), "food", restock, WithJitter(0)))
+
+	select {
+	case validator := <-validators:
+		assert.Equal(t, validator, `"v1"`)
+	case <-time.After(time.Second):
+		assert.Fail(t, "item was not refreshed")
+	}
+
+	assert.True(t, eventually(func() bool {
+		stats, ok := client.ScheduleStats("food")
+		return ok && stats.Refreshes > 0
+	}))
+
+	result, err := client.Peek("food")
+	assert.Nil(t, err)
+	assert.Equal(t, result.Value, "Pizza")
+	assert.Equal(t, result.StorageDetails.Validator, `"v2"`)
+
+	client.Unschedule("food")
+
+	assert.Nil(t, cache.Remove("food"))
+	assert.Nil(t, client.ScheduleConditional(context.Background(), "food", restock, WithRetryInterval(time.Millisecond)))
+
+	assert.True(t, eventually(func() bool {
+		stats, _ := client.ScheduleStats("food")
+		return stats.Failures > 0 && stats.LastError != nil && stats.LastError.Error() == notModifiedError
+	}))
+}
+
+func eventually(condition func() bool) bool {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
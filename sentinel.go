@@ -2,190 +2,311 @@ package fridge
 
 import (
 	"crypto/tls"
+	"errors"
+	"github.com/FZambia/go-sentinel"
+	"github.com/garyburd/redigo/redis"
 	"github.com/shomali11/xredis"
+	"math/rand"
+	"strconv"
+	"strings"
 	"time"
 )
 
-// SentinelOption an option for a sentinel option
-type SentinelOption func(*SentinelSettings)
+const (
+	masterRole                  = "master"
+	defaultSentinelAddress      = "localhost:26379"
+	defaultSentinelMasterName   = "master"
+	infoCommand                 = "INFO"
+	replicationSection          = "replication"
+	roleField                   = "role"
+	masterLinkStatusField       = "master_link_status"
+	masterLastIOField           = "master_last_io_seconds_ago"
+	masterLinkUp                = "up"
+	infoFieldSeparator          = ":"
+	masterRoleCheckError        = "connection is not to a redis master"
+	replicaLinkDownError        = "replica is not connected to its master"
+	replicaLagError             = "replica replication lag exceeds the maximum"
+	invalidReplicationInfoError = "invalid replication info"
+)
+
+// SentinelOption an option for a sentinel option.
+// Deprecated: use RedisOption
+type SentinelOption = RedisOption
+
+// SentinelSettings contains redis settings. Its former Addresses and MasterName fields
+// are now SentinelAddresses and SentinelMasterName, so custom options that set them need updating.
+// Deprecated: use RedisSettings
+type SentinelSettings = RedisSettings
 
 // WithSentinelAddresses sets sentinel addresses
-func WithSentinelAddresses(addresses []string) SentinelOption {
-	return func(redisSettings *SentinelSettings) {
-		redisSettings.Addresses = addresses
+func WithSentinelAddresses(addresses []string) RedisOption {
+	return func(redisSettings *RedisSettings) {
+		redisSettings.SentinelAddresses = addresses
 	}
 }
 
 // WithSentinelMasterName sets sentinel master name
-func WithSentinelMasterName(masterName string) SentinelOption {
-	return func(redisSettings *SentinelSettings) {
-		redisSettings.MasterName = masterName
+func WithSentinelMasterName(masterName string) RedisOption {
+	return func(redisSettings *RedisSettings) {
+		redisSettings.SentinelMasterName = masterName
 	}
 }
 
-// WithRedisPassword sets redis password
-func WithRedisPassword(password string) SentinelOption {
-	return func(redisSettings *SentinelSettings) {
-		redisSettings.Password = password
+// WithSentinelUsername sets the ACL username of the sentinels, which is separate from the redis username
+func WithSentinelUsername(username string) RedisOption {
+	return func(redisSettings *RedisSettings) {
+		redisSettings.SentinelUsername = username
 	}
 }
 
-// WithRedisDatabase sets redis database
-func WithRedisDatabase(database int) SentinelOption {
-	return func(redisSettings *SentinelSettings) {
-		redisSettings.Database = database
+// WithSentinelPassword sets the password of the sentinels, which is separate from the redis password
+func WithSentinelPassword(password string) RedisOption {
+	return func(redisSettings *RedisSettings) {
+		redisSettings.SentinelPassword = password
 	}
 }
 
-// WithRedisNetwork sets redis network
-func WithRedisNetwork(network string) SentinelOption {
-	return func(redisSettings *SentinelSettings) {
-		redisSettings.Network = network
+// WithSentinelCredentialsProvider sets a callback consulted for the username and password of every new sentinel connection
+func WithSentinelCredentialsProvider(credentialsProvider CredentialsProvider) RedisOption {
+	return func(redisSettings *RedisSettings) {
+		redisSettings.SentinelCredentialsProvider = credentialsProvider
 	}
 }
 
-// WithRedisConnectTimeout sets redis connect timeout
-func WithRedisConnectTimeout(connectTimeout time.Duration) SentinelOption {
-	return func(redisSettings *SentinelSettings) {
-		redisSettings.ConnectTimeout = connectTimeout
+// WithReplicaReads sets whether reads go to the replicas discovered by sentinel, which is the default, or to the master.
+// Writes always go to the master
+func WithReplicaReads(replicaReads bool) RedisOption {
+	return func(redisSettings *RedisSettings) {
+		redisSettings.ReplicaReads = replicaReads
 	}
 }
 
-// WithRedisWriteTimeout sets redis write timeout
-func WithRedisWriteTimeout(writeTimeout time.Duration) SentinelOption {
-	return func(redisSettings *SentinelSettings) {
-		redisSettings.WriteTimeout = writeTimeout
+// WithMaxReplicationLag skips replicas whose last contact with the master is older than the threshold.
+// A zero threshold only skips replicas whose link to the master is down
+func WithMaxReplicationLag(maxReplicationLag time.Duration) RedisOption {
+	return func(redisSettings *RedisSettings) {
+		redisSettings.MaxReplicationLag = maxReplicationLag
 	}
 }
 
-// WithRedisReadTimeout sets redis read timeout
-func WithRedisReadTimeout(readTimeout time.Duration) SentinelOption {
-	return func(redisSettings *SentinelSettings) {
-		redisSettings.ReadTimeout = readTimeout
-	}
+// WithRedisPassword sets redis password.
+// Deprecated: use WithPassword
+func WithRedisPassword(password string) RedisOption {
+	return WithPassword(password)
 }
 
-// WithRedisConnectionIdleTimeout sets redis connection idle timeout
-func WithRedisConnectionIdleTimeout(connectionIdleTimeout time.Duration) SentinelOption {
-	return func(redisSettings *SentinelSettings) {
-		redisSettings.ConnectionIdleTimeout = connectionIdleTimeout
-	}
+// WithRedisDatabase sets redis database.
+// Deprecated: use WithDatabase
+func WithRedisDatabase(database int) RedisOption {
+	return WithDatabase(database)
 }
 
-// WithRedisConnectionMaxIdle sets redis connection max idle
-func WithRedisConnectionMaxIdle(connectionMaxIdle int) SentinelOption {
-	return func(redisSettings *SentinelSettings) {
-		redisSettings.ConnectionMaxIdle = connectionMaxIdle
-	}
+// WithRedisNetwork sets redis network.
+// Deprecated: use WithNetwork
+func WithRedisNetwork(network string) RedisOption {
+	return WithNetwork(network)
 }
 
-// WithRedisConnectionMaxActive sets redis connection max active
-func WithRedisConnectionMaxActive(connectionMaxActive int) SentinelOption {
-	return func(redisSettings *SentinelSettings) {
-		redisSettings.ConnectionMaxActive = connectionMaxActive
-	}
+// WithRedisConnectTimeout sets redis connect timeout.
+// Deprecated: use WithConnectTimeout
+func WithRedisConnectTimeout(connectTimeout time.Duration) RedisOption {
+	return WithConnectTimeout(connectTimeout)
 }
 
-// WithRedisConnectionWait sets redis connection wait
-func WithRedisConnectionWait(connectionWait bool) SentinelOption {
-	return func(redisSettings *SentinelSettings) {
-		redisSettings.ConnectionWait = connectionWait
-	}
+// WithRedisWriteTimeout sets redis write timeout.
+// Deprecated: use WithWriteTimeout
+func WithRedisWriteTimeout(writeTimeout time.Duration) RedisOption {
+	return WithWriteTimeout(writeTimeout)
 }
 
-// WithRedisTlsConfig sets redis tls config
-func WithRedisTlsConfig(tlsConfig *tls.Config) SentinelOption {
-	return func(redisSettings *SentinelSettings) {
-		redisSettings.TlsConfig = tlsConfig
-	}
+// WithRedisReadTimeout sets redis read timeout.
+// Deprecated: use WithReadTimeout
+func WithRedisReadTimeout(readTimeout time.Duration) RedisOption {
+	return WithReadTimeout(readTimeout)
 }
 
-// WithRedisTlsSkipVerify sets redis tls skip verification
-func WithRedisTlsSkipVerify(tlsSkipVerify bool) SentinelOption {
-	return func(redisSettings *SentinelSettings) {
-		redisSettings.TlsSkipVerify = tlsSkipVerify
-	}
+// WithRedisConnectionIdleTimeout sets redis connection idle timeout.
+// Deprecated: use WithConnectionIdleTimeout
+func WithRedisConnectionIdleTimeout(connectionIdleTimeout time.Duration) RedisOption {
+	return WithConnectionIdleTimeout(connectionIdleTimeout)
 }
 
-// SentinelSettings contains redis settings
-type SentinelSettings struct {
-	Addresses             []string
-	MasterName            string
-	Password              string
-	Database              int
-	Network               string
-	ConnectTimeout        time.Duration
-	WriteTimeout          time.Duration
-	ReadTimeout           time.Duration
-	ConnectionIdleTimeout time.Duration
-	ConnectionMaxIdle     int
-	ConnectionMaxActive   int
-	ConnectionWait        bool
-	TlsConfig             *tls.Config
-	TlsSkipVerify         bool
+// WithRedisConnectionMaxIdle sets redis connection max idle.
+// Deprecated: use WithConnectionMaxIdle
+func WithRedisConnectionMaxIdle(connectionMaxIdle int) RedisOption {
+	return WithConnectionMaxIdle(connectionMaxIdle)
 }
 
-// NewSentinelCache creates a new redis client
-func NewSentinelCache(options ...SentinelOption) *SentinelCache {
-	settings := &SentinelSettings{}
-	for _, option := range options {
-		option(settings)
-	}
+// WithRedisConnectionMaxActive sets redis connection max active.
+// Deprecated: use WithConnectionMaxActive
+func WithRedisConnectionMaxActive(connectionMaxActive int) RedisOption {
+	return WithConnectionMaxActive(connectionMaxActive)
+}
 
-	sentinelOptions := &xredis.SentinelOptions{
-		Addresses:             settings.Addresses,
-		MasterName:            settings.MasterName,
-		Password:              settings.Password,
-		Database:              settings.Database,
-		Network:               settings.Network,
-		ConnectTimeout:        settings.ConnectTimeout,
-		WriteTimeout:          settings.WriteTimeout,
-		ReadTimeout:           settings.ReadTimeout,
-		ConnectionIdleTimeout: settings.ConnectionIdleTimeout,
-		ConnectionMaxIdle:     settings.ConnectionMaxIdle,
-		ConnectionMaxActive:   settings.ConnectionMaxActive,
-		ConnectionWait:        settings.ConnectionWait,
-		TlsConfig:             settings.TlsConfig,
-		TlsSkipVerify:         settings.TlsSkipVerify,
-	}
-	return &SentinelCache{client: xredis.SetupSentinelClient(sentinelOptions)}
+// WithRedisConnectionWait sets redis connection wait.
+// Deprecated: use WithConnectionWait
+func WithRedisConnectionWait(connectionWait bool) RedisOption {
+	return WithConnectionWait(connectionWait)
+}
+
+// WithRedisTlsConfig sets redis tls config.
+// Deprecated: use WithTlsConfig
+func WithRedisTlsConfig(tlsConfig *tls.Config) RedisOption {
+	return WithTlsConfig(tlsConfig)
+}
+
+// WithRedisTlsSkipVerify sets redis tls skip verification.
+// Deprecated: use WithTlsSkipVerify
+func WithRedisTlsSkipVerify(tlsSkipVerify bool) RedisOption {
+	return WithTlsSkipVerify(tlsSkipVerify)
+}
+
+// NewSentinelCache creates a new redis client that discovers the master and replicas through sentinel
+func NewSentinelCache(options ...RedisOption) *SentinelCache {
+	return &SentinelCache{redisClient: newSentinelRedisClient(newRedisSettings(options...))}
 }
 
 // SentinelCache contains redis client
 type SentinelCache struct {
-	client *xredis.Client
+	*redisClient
 }
 
-// Get a value by key
-func (c *SentinelCache) Get(key string) (string, bool, error) {
-	return c.client.Get(key)
+func newSentinelRedisClient(settings *RedisSettings) *redisClient {
+	settings = withSentinelDefaults(settings)
+	sentinelDetails := newSentinel(settings)
+	masterDial := func() (redis.Conn, error) {
+		address, err := sentinelDetails.MasterAddr()
+		if err != nil {
+			return nil, err
+		}
+		return dialRedis(settings, address)
+	}
+
+	client := xredis.NewClient(newRedisPool(settings, masterDial, testOnBorrow(settings, checkMasterRole)))
+	if !settings.ReplicaReads {
+		return &redisClient{client: client, readClient: client}
+	}
+
+	checkReplica := func(connection redis.Conn) error {
+		return checkReplicationLag(connection, settings.MaxReplicationLag)
+	}
+
+	replicaDial := func() (redis.Conn, error) {
+		replicas, err := sentinelDetails.Slaves()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, index := range rand.Perm(len(replicas)) {
+			replica := replicas[index]
+			if !replica.Available() {
+				continue
+			}
+
+			connection, err := dialRedis(settings, replica.Addr())
+			if err != nil {
+				continue
+			}
+
+			err = checkReplica(connection)
+			if err != nil {
+				connection.Close()
+				continue
+			}
+			return connection, nil
+		}
+		return masterDial()
+	}
+
+	replicaTestOnBorrow := func(connection redis.Conn, t time.Time) error {
+		if time.Since(t) < settings.TestOnBorrowPeriod {
+			return nil
+		}
+		return checkReplica(connection)
+	}
+
+	readClient := xredis.NewClient(newRedisPool(settings, replicaDial, replicaTestOnBorrow))
+	return &redisClient{client: client, readClient: readClient}
 }
 
-// Set a key value pair
-func (c *SentinelCache) Set(key string, value string, timeout time.Duration) error {
-	seconds := int64(timeout.Seconds())
-	if seconds == 0 {
-		_, err := c.client.Set(key, value)
-		return err
+// withSentinelDefaults returns a copy of the settings with the default sentinel address and master name when they are not set
+func withSentinelDefaults(settings *RedisSettings) *RedisSettings {
+	defaulted := *settings
+	if len(defaulted.SentinelAddresses) == 0 {
+		defaulted.SentinelAddresses = []string{defaultSentinelAddress}
 	}
 
-	_, err := c.client.SetEx(key, value, seconds)
-	return err
+	if len(defaulted.SentinelMasterName) == 0 {
+		defaulted.SentinelMasterName = defaultSentinelMasterName
+	}
+	return &defaulted
+}
+
+func newSentinel(settings *RedisSettings) *sentinel.Sentinel {
+	return &sentinel.Sentinel{
+		Addrs:      settings.SentinelAddresses,
+		MasterName: settings.SentinelMasterName,
+		Dial: func(address string) (redis.Conn, error) {
+			connection, err := redis.Dial(settings.Network, address, dialOptions(settings)...)
+			if err != nil {
+				return nil, err
+			}
+
+			err = authenticate(connection, settings.SentinelUsername, settings.SentinelPassword, settings.SentinelCredentialsProvider)
+			if err != nil {
+				connection.Close()
+				return nil, err
+			}
+			return connection, nil
+		},
+	}
 }
 
-// Remove a key
-func (c *SentinelCache) Remove(key string) error {
-	_, err := c.client.Del(key)
-	return err
+func checkMasterRole(connection redis.Conn) error {
+	if !sentinel.TestRole(connection, masterRole) {
+		return errors.New(masterRoleCheckError)
+	}
+	return nil
 }
 
-// Ping to test connectivity
-func (c *SentinelCache) Ping() error {
-	_, err := c.client.Ping()
-	return err
+func checkReplicationLag(connection redis.Conn, maxReplicationLag time.Duration) error {
+	info, err := redis.String(connection.Do(infoCommand, replicationSection))
+	if err != nil {
+		return err
+	}
+	return validateReplicationInfo(info, maxReplicationLag)
 }
 
-// Close to close resources
-func (c *SentinelCache) Close() error {
-	return c.client.Close()
+// validateReplicationInfo checks the output of "INFO replication" against the maximum replication lag.
+// Masters always pass, replicas fail when their link is down or their last contact with the master is too old
+func validateReplicationInfo(info string, maxReplicationLag time.Duration) error {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(info, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), infoFieldSeparator, 2)
+		if len(parts) == 2 {
+			fields[parts[0]] = parts[1]
+		}
+	}
+
+	if fields[roleField] == masterRole {
+		return nil
+	}
+
+	if fields[masterLinkStatusField] != masterLinkUp {
+		return errors.New(replicaLinkDownError)
+	}
+
+	if maxReplicationLag <= 0 {
+		return nil
+	}
+
+	seconds, err := strconv.Atoi(fields[masterLastIOField])
+	if err != nil {
+		return errors.New(invalidReplicationInfoError)
+	}
+
+	if time.Duration(seconds)*time.Second > maxReplicationLag {
+		return errors.New(replicaLagError)
+	}
+	return nil
 }
@@ -0,0 +1,101 @@
+package fridge
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSentinelClient_WithSentinelAddresses(t *testing.T) {
+	redisSettings := &RedisSettings{}
+
+	redisOption := WithSentinelAddresses([]string{"localhost:26379"})
+	redisOption(redisSettings)
+
+	assert.Equal(t, redisSettings.SentinelAddresses, []string{"localhost:26379"})
+}
+
+func TestSentinelClient_WithSentinelMasterName(t *testing.T) {
+	redisSettings := &RedisSettings{}
+
+	redisOption := WithSentinelMasterName("master")
+	redisOption(redisSettings)
+
+	assert.Equal(t, redisSettings.SentinelMasterName, "master")
+}
+
+func TestSentinelClient_Defaults(t *testing.T) {
+	redisSettings := newRedisSettings()
+	assert.Empty(t, redisSettings.SentinelAddresses)
+
+	sentinelDetails := newSentinel(withSentinelDefaults(redisSettings))
+	assert.Equal(t, sentinelDetails.Addrs, []string{defaultSentinelAddress})
+	assert.Equal(t, sentinelDetails.MasterName, defaultSentinelMasterName)
+	assert.Empty(t, redisSettings.SentinelAddresses)
+	assert.Empty(t, redisSettings.SentinelMasterName)
+
+	sentinelDetails = newSentinel(withSentinelDefaults(newRedisSettings(WithSentinelAddresses([]string{"sentinel:26379"}), WithSentinelMasterName("primary"))))
+	assert.Equal(t, sentinelDetails.Addrs, []string{"sentinel:26379"})
+	assert.Equal(t, sentinelDetails.MasterName, "primary")
+}
+
+func TestSentinelClient_WithSentinelCredentials(t *testing.T) {
+	redisSettings := newRedisSettings(
+		WithPassword("password"),
+		WithSentinelUsername("sentinel"),
+		WithSentinelPassword("sentinel-password"),
+		WithSentinelCredentialsProvider(func() (string, string, error) {
+			return "rotated", "rotated-password", nil
+		}))
+
+	assert.Equal(t, redisSettings.Password, "password")
+	assert.Equal(t, redisSettings.SentinelUsername, "sentinel")
+	assert.Equal(t, redisSettings.SentinelPassword, "sentinel-password")
+	assert.NotNil(t, redisSettings.SentinelCredentialsProvider)
+}
+
+func TestSentinelClient_DeprecatedOptions(t *testing.T) {
+	redisSettings := newRedisSettings(
+		WithRedisPassword("password"),
+		WithRedisDatabase(1),
+		WithRedisReadTimeout(time.Minute),
+		WithRedisConnectionMaxIdle(11))
+
+	assert.Equal(t, redisSettings.Password, "password")
+	assert.Equal(t, redisSettings.Database, 1)
+	assert.Equal(t, redisSettings.ReadTimeout, time.Minute)
+	assert.Equal(t, redisSettings.ConnectionMaxIdle, 11)
+}
+
+func TestSentinelClient_WithReplicaReads(t *testing.T) {
+	redisSettings := &RedisSettings{}
+
+	redisOption := WithReplicaReads(true)
+	redisOption(redisSettings)
+
+	assert.Equal(t, redisSettings.ReplicaReads, true)
+}
+
+func TestSentinelClient_WithMaxReplicationLag(t *testing.T) {
+	redisSettings := &RedisSettings{}
+
+	redisOption := WithMaxReplicationLag(time.Second)
+	redisOption(redisSettings)
+
+	assert.Equal(t, redisSettings.MaxReplicationLag, time.Second)
+}
+
+func TestSentinelClient_ValidateReplicationInfo(t *testing.T) {
+	master := "# Replication\r\nrole:master\r\nconnected_slaves:1\r\n"
+	assert.Nil(t, validateReplicationInfo(master, time.Second))
+
+	fresh := "# Replication\r\nrole:slave\r\nmaster_link_status:up\r\nmaster_last_io_seconds_ago:1\r\n"
+	assert.Nil(t, validateReplicationInfo(fresh, 5*time.Second))
+
+	stale := "# Replication\r\nrole:slave\r\nmaster_link_status:up\r\nmaster_last_io_seconds_ago:10\r\n"
+	assert.NotNil(t, validateReplicationInfo(stale, 5*time.Second))
+	assert.Nil(t, validateReplicationInfo(stale, 0))
+
+	down := "# Replication\r\nrole:slave\r\nmaster_link_status:down\r\nmaster_last_io_seconds_ago:-1\r\n"
+	assert.NotNil(t, validateReplicationInfo(down, 0))
+}
@@ -4,6 +4,21 @@ import (
 	"time"
 )
 
+const (
+	storageDetailsVersion = 1
+)
+
+const (
+	// InvalidateBestBy marks an item as past its "Best By" duration, so that the next read returns it and restocks it in the background
+	InvalidateBestBy Invalidation = iota + 1
+
+	// InvalidateUseBy marks an item as past its "Use By" duration, so that the next read restocks it before returning it
+	InvalidateUseBy
+)
+
+// Invalidation decides which duration an invalidated item is treated as having passed
+type Invalidation int
+
 // StorageOption an option for a storage
 type StorageOption func(*StorageDetails)
 
@@ -15,12 +30,40 @@ func WithDurations(bestBy time.Duration, useBy time.Duration) StorageOption {
 	}
 }
 
+// WithTags sets the tags of an item, so that it can be invalidated along with every other item carrying one of them
+func WithTags(tags ...string) StorageOption {
+	return func(storageInfo *StorageDetails) {
+		storageInfo.Tags = tags
+	}
+}
+
+// WithValidator sets the validator of an item, such as an ETag or a Last-Modified date, which is given to conditional restocks
+func WithValidator(validator string) StorageOption {
+	return func(storageInfo *StorageDetails) {
+		storageInfo.Validator = validator
+	}
+}
+
+// withRestockDuration records how long the restock function took
+func withRestockDuration(restockDuration time.Duration) StorageOption {
+	return func(storageInfo *StorageDetails) {
+		storageInfo.RestockDuration = restockDuration
+	}
+}
+
 // StorageDetails contains storage information
 type StorageDetails struct {
-	Timestamp  time.Time
-	Restocking bool
-	BestBy     time.Duration
-	UseBy      time.Duration
+	Version         int
+	Timestamp       time.Time
+	Restocking      bool
+	BestBy          time.Duration
+	UseBy           time.Duration
+	Chunks          int
+	Checksum        string
+	RestockDuration time.Duration
+	Invalidation    Invalidation
+	Tags            []string
+	Validator       string
 }
 
 func newStorageDetails(defaults *Defaults, options ...StorageOption) *StorageDetails {
@@ -39,3 +39,21 @@ func TestStorageDetails_Override(t *testing.T) {
 	assert.Equal(t, storageDetails.BestBy, time.Second)
 	assert.Equal(t, storageDetails.UseBy, 2*time.Second)
 }
+
+func TestStorageDetails_WithTags(t *testing.T) {
+	storageDetails := &StorageDetails{}
+
+	storageOption := WithTags("customer:1", "customer:2")
+	storageOption(storageDetails)
+
+	assert.Equal(t, storageDetails.Tags, []string{"customer:1", "customer:2"})
+}
+
+func TestStorageDetails_WithValidator(t *testing.T) {
+	storageDetails := &StorageDetails{}
+
+	storageOption := WithValidator(`"v1"`)
+	storageOption(storageDetails)
+
+	assert.Equal(t, storageDetails.Validator, `"v1"`)
+}
@@ -0,0 +1,162 @@
+package fridge
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWarmConcurrency = 1
+)
+
+// WarmSummary reports the outcome of warming a list of keys
+type WarmSummary struct {
+	Loaded  []string
+	Skipped []string
+	Failed  map[string]error
+}
+
+// Warm loads keys that are not fresh through the loader and puts them with the default durations, keeping the tags of
+// cached items. A nil loader falls back to the loaders registered for the keys. Keys are loaded concurrently, and keys
+// that were not attempted before the context was done are left out of the summary
+func (c *Client) Warm(ctx context.Context, keys []string, loader Loader, concurrency int) (*WarmSummary, error) {
+	return c.WarmConditional(ctx, keys, unconditionalLoader(loader), concurrency)
+}
+
+// WarmConditional warms keys like Warm, with a loader that is given the cached value and validator of keys that are not fresh
+func (c *Client) WarmConditional(ctx context.Context, keys []string, loader ConditionalLoader, concurrency int) (*WarmSummary, error) {
+	if concurrency <= 0 {
+		concurrency = defaultWarmConcurrency
+	}
+
+	summary := &WarmSummary{Failed: make(map[string]error)}
+	mutex := &sync.Mutex{}
+	waitGroup := &sync.WaitGroup{}
+	keysChannel := make(chan string)
+
+	for index := 0; index < concurrency; index++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for key := range keysChannel {
+				loaded, err := c.warm(key, loader)
+
+				mutex.Lock()
+				switch {
+				case err != nil:
+					summary.Failed[key] = err
+				case loaded:
+					summary.Loaded = append(summary.Loaded, key)
+				default:
+					summary.Skipped = append(summary.Skipped, key)
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	var err error
+	for _, key := range keys {
+		err = ctx.Err()
+		if err != nil {
+			break
+		}
+
+		select {
+		case keysChannel <- key:
+			continue
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		break
+	}
+
+	close(keysChannel)
+	waitGroup.Wait()
+	return summary, err
+}
+
+func (c *Client) warm(key string, loader ConditionalLoader) (bool, error) {
+	cachedValue, storageDetails, found, err := c.cached(key)
+	if err != nil {
+		c.publish(key, WarmFailed)
+		return false, err
+	}
+
+	if found && freshness(storageDetails, time.Now().UTC()) == Fresh {
+		c.publish(key, WarmSkipped)
+		return false, nil
+	}
+
+	restock := c.findRestock(key)
+	if loader != nil {
+		restock = loader.restock(key)
+	}
+
+	if restock == nil {
+		c.publish(key, WarmFailed)
+		return false, errors.New(nilRestockError)
+	}
+
+	validator := empty
+	if found {
+		validator = storageDetails.Validator
+	}
+
+	start := time.Now()
+	value, validator, modified, err := restock(cachedValue, validator)
+	options := []StorageOption{WithValidator(validator), withRestockDuration(time.Since(start))}
+	if found {
+		options = append(options, WithTags(storageDetails.Tags...))
+	}
+
+	if err == nil && !modified {
+		value = cachedValue
+		if !found {
+			err = errors.New(notModifiedError)
+		}
+	}
+
+	if err != nil {
+		c.publish(key, WarmFailed)
+		return false, err
+	}
+
+	storageDetails, err = c.put(key, value, options...)
+	if err != nil {
+		c.publish(key, WarmFailed)
+		return false, err
+	}
+
+	if storageDetails == nil {
+		c.publish(key, WarmSkipped)
+		return false, nil
+	}
+
+	c.publish(key, Warmed)
+	return true, nil
+}
+
+// cached returns the cached value of a key and its storage details, treating corrupt metadata as missing
+func (c *Client) cached(key string) (string, *StorageDetails, bool, error) {
+	storageDetails, found, err := c.dao.GetStorageDetails(key)
+	if err != nil {
+		_, ok := err.(*CorruptMetadataError)
+		if !ok {
+			return empty, nil, false, err
+		}
+		return empty, nil, false, nil
+	}
+
+	if !found {
+		return empty, nil, false, nil
+	}
+
+	cachedValue, found, err := c.dao.Load(key, storageDetails)
+	if err != nil || !found {
+		return empty, nil, false, err
+	}
+	return cachedValue, storageDetails, true, nil
+}
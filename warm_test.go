@@ -0,0 +1,113 @@
+package fridge
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"testing"
+)
+
+func TestClient_Warm(t *testing.T) {
+	cache := newMemoryCache()
+	client := NewClient(cache)
+	defer client.Close()
+
+	assert.Nil(t, client.Put("food1", "Pizza"))
+
+	events := make(chan *Event, 10)
+	client.HandleEvent(func(event *Event) {
+		events <- event
+	})
+
+	loader := func(key string) (string, error) {
+		if key == "food3" {
+			return empty, errors.New("out of stock")
+		}
+		return "Hot Pizza", nil
+	}
+
+	summary, err := client.Warm(context.Background(), []string{"food1", "food2", "food3", "food4"}, loader, 2)
+	assert.Nil(t, err)
+
+	sort.Strings(summary.Loaded)
+	assert.Equal(t, summary.Loaded, []string{"food2", "food4"})
+	assert.Equal(t, summary.Skipped, []string{"food1"})
+	assert.Len(t, summary.Failed, 1)
+	assert.Contains(t, summary.Failed, "food3")
+
+	assert.Equal(t, cache.memory["food1"], "Pizza")
+	assert.Equal(t, cache.memory["food2"], "Hot Pizza")
+	assert.Equal(t, cache.timeouts["food2"], defaultUseBy)
+
+	eventTypes := make(map[string]string)
+	for range []string{"food1", "food2", "food3", "food4"} {
+		event := <-events
+		eventTypes[event.Key] = event.Type
+	}
+	assert.Equal(t, eventTypes, map[string]string{"food1": WarmSkipped, "food2": Warmed, "food3": WarmFailed, "food4": Warmed})
+}
+
+func TestClient_WarmCanceled(t *testing.T) {
+	cache := newMemoryCache()
+	client := NewClient(cache)
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	loader := func(key string) (string, error) {
+		cancel()
+		return "Hot Pizza", nil
+	}
+
+	summary, err := client.Warm(ctx, []string{"food1", "food2", "food3"}, loader, 1)
+	assert.Equal(t, err, context.Canceled)
+	assert.Equal(t, summary.Loaded, []string{"food1"})
+}
+
+func TestClient_WarmInvalidated(t *testing.T) {
+	cache := newMemoryCache()
+	client := NewClient(cache)
+	defer client.Close()
+
+	assert.Nil(t, client.Put("food", "Pizza"))
+
+	_, err := client.Invalidate("food", InvalidateUseBy)
+	assert.Nil(t, err)
+
+	loader := func(key string) (string, error) {
+		return "Hot Pizza", nil
+	}
+
+	summary, err := client.Warm(context.Background(), []string{"food"}, loader, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, summary.Loaded, []string{"food"})
+	assert.Empty(t, summary.Skipped)
+	assert.Equal(t, cache.memory["food"], "Hot Pizza")
+}
+
+func TestClient_WarmTagged(t *testing.T) {
+	cache := newMemoryCache()
+	client := NewClient(cache)
+	defer client.Close()
+
+	assert.Nil(t, client.Put("food", "Pizza", WithTags("customer")))
+
+	_, err := client.Invalidate("food", InvalidateUseBy)
+	assert.Nil(t, err)
+
+	loader := func(key string) (string, error) {
+		return "Hot Pizza", nil
+	}
+
+	summary, err := client.Warm(context.Background(), []string{"food"}, loader, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, summary.Loaded, []string{"food"})
+
+	result, err := client.Peek("food")
+	assert.Nil(t, err)
+	assert.Equal(t, result.StorageDetails.Tags, []string{"customer"})
+
+	invalidated, err := client.InvalidateTag("customer", InvalidateUseBy)
+	assert.Nil(t, err)
+	assert.Equal(t, invalidated, 1)
+}